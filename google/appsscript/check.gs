@@ -41,24 +41,114 @@ function sendSheetData() {
   range.clearNote();
 
   var response = UrlFetchApp.fetch(url, options);
-  var t = response.getContentText()
-  if (t.length == 2) {
+  var result = JSON.parse(response.getContentText());
+  if (result.findings.length == 0) {
     SpreadsheetApp.getUi().alert('Looks good! 🚀');
     return;
   }
-  var result = JSON.parse(t);
   displayErrors(result);
 }
 
-function displayErrors(e) {
+var severityColors = {
+  error: 'red',
+  warning: 'yellow',
+  info: 'lightblue'
+};
+
+var severityRank = {
+  info: 0,
+  warning: 1,
+  error: 2
+};
+
+function displayErrors(result) {
   var sheet = SpreadsheetApp.getActiveSpreadsheet().getActiveSheet();
 
-  var count = 0;
-  for (var cell in e) {
-    var error = e[cell];
-    sheet.getRange(cell).setBackground('red').setNote(error);
-    count += 1;
+  // a cell can have several findings, show them all in one note and colour
+  // the cell by the most severe
+  var cells = {};
+  for (var i = 0; i < result.findings.length; i++) {
+    var finding = result.findings[i];
+    var note = finding.message;
+    if (finding.value) {
+      note += ' (value ' + finding.position + ': ' + finding.value + ')';
+    }
+    if (finding.fix) {
+      note += '\nFix: ' + finding.fix;
+    }
+    var cell = cells[finding.cell];
+    if (!cell) {
+      cells[finding.cell] = { severity: finding.severity, notes: [note] };
+      continue;
+    }
+    cell.notes.push(note);
+    if (severityRank[finding.severity] > severityRank[cell.severity]) {
+      cell.severity = finding.severity;
+    }
+  }
+
+  for (var name in cells) {
+    sheet.getRange(name).setBackground(severityColors[cells[name].severity]).setNote(cells[name].notes.join('\n\n'));
+  }
+
+  SpreadsheetApp.getUi().alert('Found ' + result.errors + ' errors and ' + result.warnings + ' warnings highlighted in the sheet.');
+  showSuggestions(sheet, result.findings);
+}
+
+// showSuggestions opens a sidebar with a button for each candidate value the
+// check suggested, e.g. TGN URIs for a place name, so it can be swapped in
+// with one click.
+function showSuggestions(sheet, findings) {
+  var items = [];
+  for (var i = 0; i < findings.length; i++) {
+    var finding = findings[i];
+    if (!finding.candidates || finding.candidates.length == 0) {
+      continue;
+    }
+    items.push({
+      cell: finding.cell,
+      value: finding.value || sheet.getRange(finding.cell).getValue().toString(),
+      candidates: finding.candidates.map(function(c) {
+        return { uri: c.uri, label: c.label + ' (' + c.parents + ')' };
+      })
+    });
+  }
+  if (items.length == 0) {
+    return;
   }
 
-  SpreadsheetApp.getUi().alert('Found ' + count + ' errors highlighted in the sheet.');
+  var html = '<style>button { display: block; margin: 4px 0; text-align: left; width: 100%; }</style>';
+  for (var i = 0; i < items.length; i++) {
+    html += '<h4>' + escapeHtml(items[i].cell + ': ' + items[i].value) + '</h4>';
+    for (var j = 0; j < items[i].candidates.length; j++) {
+      html += '<button id="b' + i + '-' + j + '" onclick="apply(' + i + ',' + j + ')">' + escapeHtml(items[i].candidates[j].label) + '</button>';
+    }
+  }
+  html += '<script>var items = ' + JSON.stringify(items).replace(/</g, '\\u003c') + ';' +
+    'function apply(i, j) {' +
+    '  var item = items[i];' +
+    '  google.script.run.withSuccessHandler(function() {' +
+    '    document.getElementById("b" + i + "-" + j).textContent = "Replaced ✔";' +
+    '  }).replaceCellValue(item.cell, item.value, item.candidates[j].uri);' +
+    '}</script>';
+
+  SpreadsheetApp.getUi().showSidebar(HtmlService.createHtmlOutput(html).setTitle('Suggestions'));
+}
+
+// replaceCellValue swaps oldValue for newValue, leaving any other " ; "
+// separated values in the cell alone.
+function replaceCellValue(cell, oldValue, newValue) {
+  var range = SpreadsheetApp.getActiveSpreadsheet().getActiveSheet().getRange(cell);
+  var values = range.getValue().toString().split(' ; ').map(function(v) {
+    return v.trim() == oldValue ? newValue : v;
+  });
+  range.setValue(values.join(' ; ')).setBackground(null).clearNote();
+}
+
+function escapeHtml(s) {
+  return String(s)
+    .replace(/&/g, '&amp;')
+    .replace(/</g, '&lt;')
+    .replace(/>/g, '&gt;')
+    .replace(/"/g, '&quot;');
 }
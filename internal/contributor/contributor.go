@@ -1,9 +1,18 @@
 package contributor
 
+// Contributor is one value of a sheet's Contributor column.
 type Contributor struct {
-	Name        string `json:"name"`
+	// Name is the packed relators:aut:person:Name, or just the name when
+	// Relator and Vocab are given. See ParseName.
+	Name    string `json:"name"`
+	Relator string `json:"relator,omitempty"`
+	Vocab   string `json:"vocab,omitempty"`
+
 	Orcid       string `json:"orcid,omitempty"`
 	Institution string `json:"institution,omitempty"`
-	Email       string `json:"email,omitempty"`
-	Status      string `json:"status,omitempty"`
+	// InstitutionRor is the institution's ROR ID, which finds its term
+	// whatever name the sheet gives it.
+	InstitutionRor string `json:"institution_ror,omitempty"`
+	Email          string `json:"email,omitempty"`
+	Status         string `json:"status,omitempty"`
 }
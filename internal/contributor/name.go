@@ -0,0 +1,55 @@
+package contributor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNameFormat is returned for a packed name that doesn't have a relator,
+	// vocab and name.
+	ErrNameFormat = errors.New("contributor name is not formatted relators:aut:person:Name")
+	// ErrPartialName is returned when only one of relator and vocab is given
+	// as its own key.
+	ErrPartialName = errors.New("contributor relator and vocab must be given together")
+)
+
+// Name is a contributor's name split into its parts.
+type Name struct {
+	// Relator is the relator with its scheme, e.g. relators:aut.
+	Relator string
+	// Vocab is the vocabulary the contributor's term is in, e.g. person.
+	Vocab string
+	// Display is the name itself, which may contain colons.
+	Display string
+}
+
+// String packs n the way sheets always have, relators:aut:person:Name.
+func (n Name) String() string {
+	return n.Relator + ":" + n.Vocab + ":" + n.Display
+}
+
+// ParseName splits c's name into its relator, vocab and display name. They are
+// read from the relator, vocab and name keys when given, where a relator
+// without a scheme is a MARC relator, e.g. aut is relators:aut. Otherwise
+// name is the packed relators:aut:person:Name, where everything after the
+// vocab is the name.
+func (c Contributor) ParseName() (Name, error) {
+	if c.Relator != "" || c.Vocab != "" {
+		if c.Relator == "" || c.Vocab == "" {
+			return Name{}, fmt.Errorf("%w: %s", ErrPartialName, c.Name)
+		}
+		relator := c.Relator
+		if !strings.Contains(relator, ":") {
+			relator = "relators:" + relator
+		}
+		return Name{Relator: relator, Vocab: c.Vocab, Display: c.Name}, nil
+	}
+
+	parts := strings.SplitN(c.Name, ":", 4)
+	if len(parts) < 4 {
+		return Name{}, fmt.Errorf("%w: %s", ErrNameFormat, c.Name)
+	}
+	return Name{Relator: parts[0] + ":" + parts[1], Vocab: parts[2], Display: parts[3]}, nil
+}
@@ -0,0 +1,112 @@
+package contributor
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  Name
+		err   error
+	}{
+		{
+			name:  "packed",
+			value: `{"name":"relators:aut:person:Smith, Sam"}`,
+			want:  Name{Relator: "relators:aut", Vocab: "person", Display: "Smith, Sam"},
+		},
+		{
+			name:  "packed name with colons",
+			value: `{"name":"relators:pbl:corporate_body:Lehigh University: Office of the Provost"}`,
+			want:  Name{Relator: "relators:pbl", Vocab: "corporate_body", Display: "Lehigh University: Office of the Provost"},
+		},
+		{
+			name:  "packed blank name",
+			value: `{"name":"relators:aut:person:"}`,
+			want:  Name{Relator: "relators:aut", Vocab: "person"},
+		},
+		{
+			name:  "packed without a name",
+			value: `{"name":"relators:aut:person"}`,
+			err:   ErrNameFormat,
+		},
+		{
+			name:  "keys",
+			value: `{"relator":"relators:aut","vocab":"person","name":"Smith: Sam"}`,
+			want:  Name{Relator: "relators:aut", Vocab: "person", Display: "Smith: Sam"},
+		},
+		{
+			name:  "keys with a bare MARC relator",
+			value: `{"relator":"ths","vocab":"person","name":"Smith, Sam"}`,
+			want:  Name{Relator: "relators:ths", Vocab: "person", Display: "Smith, Sam"},
+		},
+		{
+			name:  "keys win over a packed name",
+			value: `{"relator":"relators:edt","vocab":"person","name":"relators:aut:person:Smith"}`,
+			want:  Name{Relator: "relators:edt", Vocab: "person", Display: "relators:aut:person:Smith"},
+		},
+		{
+			name:  "relator without vocab",
+			value: `{"relator":"relators:aut","name":"Smith, Sam"}`,
+			err:   ErrPartialName,
+		},
+		{
+			name:  "vocab without relator",
+			value: `{"vocab":"person","name":"relators:aut:person:Smith, Sam"}`,
+			err:   ErrPartialName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Contributor
+			if err := json.Unmarshal([]byte(tt.value), &c); err != nil {
+				t.Fatalf("failed decoding %s: %v", tt.value, err)
+			}
+			got, err := c.ParseName()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// The schema documents every key a Contributor reads and no others.
+func TestSchemaMatchesContributor(t *testing.T) {
+	raw, err := os.ReadFile("contributor.schema.json")
+	if err != nil {
+		t.Fatalf("failed reading schema: %v", err)
+	}
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	var documented []string
+	for key := range schema.Properties {
+		documented = append(documented, key)
+	}
+
+	var keys []string
+	typ := reflect.TypeOf(Contributor{})
+	for i := range typ.NumField() {
+		key, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		keys = append(keys, key)
+	}
+
+	sort.Strings(documented)
+	sort.Strings(keys)
+	if !reflect.DeepEqual(documented, keys) {
+		t.Fatalf("expected the schema to document %v, got %v", keys, documented)
+	}
+}
@@ -0,0 +1,65 @@
+package contributor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrOrcidFormat is returned for an ORCiD that isn't 16 digits, the last
+	// of which may be X, in four hyphenated groups.
+	ErrOrcidFormat = errors.New("ORCiD is not formatted 0000-0000-0000-000X")
+	// ErrOrcidChecksum is returned for an ORCiD whose last digit doesn't match
+	// the others, usually a typo.
+	ErrOrcidChecksum = errors.New("ORCiD check digit does not match")
+)
+
+// NormalizeOrcid returns orcid as a bare iD, e.g. 0000-0002-1825-0097, after
+// checking its ISO 7064 MOD 11-2 check digit. Bare iDs and the
+// https://orcid.org/ form are accepted.
+func NormalizeOrcid(orcid string) (string, error) {
+	id := strings.ToUpper(strings.TrimSpace(orcid))
+	for _, prefix := range []string{"HTTPS://ORCID.ORG/", "HTTP://ORCID.ORG/", "ORCID.ORG/"} {
+		if strings.HasPrefix(id, prefix) {
+			id = strings.TrimPrefix(id, prefix)
+			break
+		}
+	}
+
+	if len(id) != 19 {
+		return "", fmt.Errorf("%w: %s", ErrOrcidFormat, orcid)
+	}
+	digits := make([]byte, 0, 16)
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		switch {
+		case i == 4 || i == 9 || i == 14:
+			if ch != '-' {
+				return "", fmt.Errorf("%w: %s", ErrOrcidFormat, orcid)
+			}
+		case ch >= '0' && ch <= '9', ch == 'X' && i == len(id)-1:
+			digits = append(digits, ch)
+		default:
+			return "", fmt.Errorf("%w: %s", ErrOrcidFormat, orcid)
+		}
+	}
+
+	if orcidCheckDigit(digits[:15]) != digits[15] {
+		return "", fmt.Errorf("%w: %s", ErrOrcidChecksum, orcid)
+	}
+	return id, nil
+}
+
+// orcidCheckDigit computes the ISO 7064 MOD 11-2 check digit of base.
+func orcidCheckDigit(base []byte) byte {
+	total := 0
+	for _, d := range base {
+		total = (total + int(d-'0')) * 2
+	}
+	result := (12 - total%11) % 11
+	if result == 10 {
+		return 'X'
+	}
+	return byte('0' + result)
+}
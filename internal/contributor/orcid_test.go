@@ -0,0 +1,38 @@
+package contributor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeOrcid(t *testing.T) {
+	tests := []struct {
+		orcid string
+		want  string
+		err   error
+	}{
+		{orcid: "0000-0002-1825-0097", want: "0000-0002-1825-0097"},
+		{orcid: " https://orcid.org/0000-0002-1825-0097 ", want: "0000-0002-1825-0097"},
+		{orcid: "http://orcid.org/0000-0002-1825-0097", want: "0000-0002-1825-0097"},
+		{orcid: "orcid.org/0000-0002-1825-0097", want: "0000-0002-1825-0097"},
+		{orcid: "0000-0002-1694-233x", want: "0000-0002-1694-233X"},
+		{orcid: "0000-0002-1825-0098", err: ErrOrcidChecksum},
+		{orcid: "0000-0000-0000-0000", err: ErrOrcidChecksum},
+		{orcid: "0000000218250097", err: ErrOrcidFormat},
+		{orcid: "0000-0002-1825-009", err: ErrOrcidFormat},
+		{orcid: "0000-000X-1825-0097", err: ErrOrcidFormat},
+		{orcid: "https://example.com/0000-0002-1825-0097", err: ErrOrcidFormat},
+		{orcid: "", err: ErrOrcidFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.orcid, func(t *testing.T) {
+			got, err := NormalizeOrcid(tt.orcid)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
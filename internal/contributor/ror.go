@@ -0,0 +1,53 @@
+package contributor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrRorFormat is returned for a ROR ID that isn't a 0 followed by six
+	// letters or digits and a two digit checksum.
+	ErrRorFormat = errors.New("ROR ID is not formatted 0xxxxxx00")
+	// ErrRorChecksum is returned for a ROR ID whose last two digits don't
+	// match the others, usually a typo.
+	ErrRorChecksum = errors.New("ROR ID checksum does not match")
+)
+
+// rorAlphabet is Crockford's base32, which ROR IDs are written in.
+const rorAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// NormalizeRor returns ror as a bare ID, e.g. 012afjb06, after checking its
+// ISO 7064 MOD 97-10 checksum. Bare IDs and the https://ror.org/ form are
+// accepted.
+func NormalizeRor(ror string) (string, error) {
+	id := strings.ToLower(strings.TrimSpace(ror))
+	for _, prefix := range []string{"https://ror.org/", "http://ror.org/", "ror.org/"} {
+		if strings.HasPrefix(id, prefix) {
+			id = strings.TrimPrefix(id, prefix)
+			break
+		}
+	}
+
+	if len(id) != 9 || id[0] != '0' {
+		return "", fmt.Errorf("%w: %s", ErrRorFormat, ror)
+	}
+	value := 0
+	for i := 0; i < 7; i++ {
+		digit := strings.IndexByte(rorAlphabet, id[i])
+		if digit < 0 {
+			return "", fmt.Errorf("%w: %s", ErrRorFormat, ror)
+		}
+		value = value*32 + digit
+	}
+	if id[7] < '0' || id[7] > '9' || id[8] < '0' || id[8] > '9' {
+		return "", fmt.Errorf("%w: %s", ErrRorFormat, ror)
+	}
+
+	checksum := int(id[7]-'0')*10 + int(id[8]-'0')
+	if 98-(value*100)%97 != checksum {
+		return "", fmt.Errorf("%w: %s", ErrRorChecksum, ror)
+	}
+	return id, nil
+}
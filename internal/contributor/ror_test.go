@@ -0,0 +1,37 @@
+package contributor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeRor(t *testing.T) {
+	tests := []struct {
+		ror  string
+		want string
+		err  error
+	}{
+		{ror: "012afjb06", want: "012afjb06"},
+		{ror: " https://ror.org/012afjb06 ", want: "012afjb06"},
+		{ror: "HTTP://ROR.ORG/00F54P054", want: "00f54p054"},
+		{ror: "ror.org/05dxps055", want: "05dxps055"},
+		{ror: "012afjb07", err: ErrRorChecksum},
+		{ror: "112afjb06", err: ErrRorFormat},
+		{ror: "012afjb6", err: ErrRorFormat},
+		{ror: "012afib06", err: ErrRorFormat},
+		{ror: "012afjbx6", err: ErrRorFormat},
+		{ror: "https://example.org/012afjb06", err: ErrRorFormat},
+		{ror: "", err: ErrRorFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ror, func(t *testing.T) {
+			got, err := NormalizeRor(tt.ror)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sharedSecretName is the identity requests authenticated with the legacy
+// SHARED_SECRET env var are logged as.
+const sharedSecretName = "shared-secret"
+
+// apiKey is a named secret a non-interactive client, like the GitHub runner
+// or the ETD cron, sends in X-Secret. Only the key's hash is kept.
+type apiKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// Role defaults to editor, which can still transform sheets as every key
+	// could before roles.
+	Role Role `json:"role,omitempty"`
+
+	hash [sha256.Size]byte
+}
+
+var activeAPIKeys []apiKey
+
+// LoadAPIKeys replaces the configured API keys with those in the JSON file at
+// path, a list of {"name", "key", "role"} objects, and inline, a comma
+// separated list of name:key pairs where the name may end in @role. Several
+// keys may share a name so a consumer's key can be rotated without downtime.
+// It should be called once at startup.
+func LoadAPIKeys(path, inline string) error {
+	var keys []apiKey
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read API keys %s: %w", path, err)
+		}
+		if err := json.Unmarshal(raw, &keys); err != nil {
+			return fmt.Errorf("invalid API keys %s: %w", path, err)
+		}
+	}
+	for _, pair := range strings.Split(inline, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(pair, ":")
+		if !ok {
+			return fmt.Errorf("invalid API key %q, expected name:key", strings.TrimSpace(name))
+		}
+		var role Role
+		if at := strings.LastIndex(name, "@"); at > 0 {
+			if r, err := parseRole(name[at+1:]); err == nil {
+				name, role = name[:at], r
+			}
+		}
+		keys = append(keys, apiKey{Name: name, Key: key, Role: role})
+	}
+
+	parsed, err := parseAPIKeys(keys)
+	if err != nil {
+		return err
+	}
+	activeAPIKeys = parsed
+
+	return nil
+}
+
+func parseAPIKeys(keys []apiKey) ([]apiKey, error) {
+	seen := map[[sha256.Size]byte]string{}
+	for i := range keys {
+		keys[i].Name = strings.TrimSpace(keys[i].Name)
+		if keys[i].Name == "" {
+			return nil, fmt.Errorf("API key %d has no name", i+1)
+		}
+		if keys[i].Key == "" {
+			return nil, fmt.Errorf("API key %s is empty", keys[i].Name)
+		}
+		if keys[i].Role == "" {
+			keys[i].Role = RoleEditor
+		}
+		role, err := parseRole(string(keys[i].Role))
+		if err != nil {
+			return nil, fmt.Errorf("API key %s: %w", keys[i].Name, err)
+		}
+		keys[i].Role = role
+		keys[i].hash = sha256.Sum256([]byte(keys[i].Key))
+		keys[i].Key = ""
+		if other, ok := seen[keys[i].hash]; ok {
+			return nil, fmt.Errorf("API keys %s and %s are the same", other, keys[i].Name)
+		}
+		seen[keys[i].hash] = keys[i].Name
+	}
+	return keys, nil
+}
+
+// matchAPIKey returns the key secret matches. Every key is compared in
+// constant time so a caller can't learn how close a guess was, and an empty
+// secret never matches.
+func matchAPIKey(secret string) (apiKey, bool) {
+	if secret == "" {
+		return apiKey{}, false
+	}
+	keys := activeAPIKeys
+	// SHARED_SECRET is read per request as it always has been, and can do
+	// everything it always could
+	if legacy := os.Getenv("SHARED_SECRET"); legacy != "" {
+		keys = append(keys[:len(keys):len(keys)], apiKey{Name: sharedSecretName, Role: RoleIngester, hash: sha256.Sum256([]byte(legacy))})
+	}
+
+	hash := sha256.Sum256([]byte(secret))
+	var match apiKey
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare(hash[:], key.hash[:]) == 1 && match.Name == "" {
+			match = key
+		}
+	}
+	return match, match.Name != ""
+}
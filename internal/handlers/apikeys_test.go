@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadAPIKeys(t *testing.T) {
+	original := activeAPIKeys
+	defer func() {
+		activeAPIKeys = original
+	}()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keys := `[
+  {"name": "github-runner", "key": "runner-old", "role": "ingester"},
+  {"name": "github-runner", "key": "runner-new", "role": "ingester"},
+  {"name": "etd-cron", "key": "cron"}
+]`
+	if err := os.WriteFile(path, []byte(keys), 0600); err != nil {
+		t.Fatalf("failed writing keys: %v", err)
+	}
+	if err := LoadAPIKeys(path, "alice@editor:dev-key, bob@lehigh.edu:other-key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Setenv("SHARED_SECRET", "legacy")
+
+	tests := []struct {
+		secret string
+		name   string
+		role   Role
+	}{
+		{secret: "runner-old", name: "github-runner", role: RoleIngester},
+		{secret: "runner-new", name: "github-runner", role: RoleIngester},
+		{secret: "cron", name: "etd-cron", role: RoleEditor},
+		{secret: "dev-key", name: "alice", role: RoleEditor},
+		// @ only introduces a role when one follows
+		{secret: "other-key", name: "bob@lehigh.edu", role: RoleEditor},
+		{secret: "legacy", name: sharedSecretName, role: RoleIngester},
+		{secret: "cro", name: ""},
+		{secret: "", name: ""},
+	}
+	for _, tt := range tests {
+		key, ok := matchAPIKey(tt.secret)
+		if key.Name != tt.name || key.Role != tt.role || ok != (tt.name != "") {
+			t.Errorf("matchAPIKey(%q) = %q %q, %v; expected %q %q", tt.secret, key.Name, key.Role, ok, tt.name, tt.role)
+		}
+	}
+
+	// an unset SHARED_SECRET never matches an empty header
+	t.Setenv("SHARED_SECRET", "")
+	if _, ok := matchAPIKey(""); ok {
+		t.Fatal("expected an empty secret not to match")
+	}
+	if _, ok := matchAPIKey("legacy"); ok {
+		t.Fatal("expected the old shared secret to stop working once unset")
+	}
+}
+
+func TestLoadAPIKeysInvalid(t *testing.T) {
+	original := activeAPIKeys
+	defer func() {
+		activeAPIKeys = original
+	}()
+
+	tests := []struct {
+		name    string
+		path    string
+		inline  string
+		wantErr string
+	}{
+		{name: "empty key", inline: "runner:", wantErr: "API key runner is empty"},
+		{name: "no name", inline: ":abc", wantErr: "API key 1 has no name"},
+		{name: "no separator", inline: "runner", wantErr: "expected name:key"},
+		{name: "reused key", inline: "runner:abc,cron:abc", wantErr: "API keys runner and cron are the same"},
+		{name: "unknown role", path: `[{"name":"runner","key":"abc","role":"admin"}]`, wantErr: `API key runner: unknown role "admin"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.path != "" {
+				path = filepath.Join(t.TempDir(), "keys.json")
+				if err := os.WriteFile(path, []byte(tt.path), 0600); err != nil {
+					t.Fatalf("failed writing keys: %v", err)
+				}
+			}
+			err := LoadAPIKeys(path, tt.inline)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRequireAuthIdentity(t *testing.T) {
+	original := activeAPIKeys
+	defer func() {
+		activeAPIKeys = original
+	}()
+	if err := LoadAPIKeys("", "etd-cron:cron"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	j := useTestJWKS(t)
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		identity Identity
+	}{
+		{name: "API key", header: "X-Secret", value: "cron", identity: Identity{Name: "etd-cron", Method: "api-key", Role: RoleEditor}},
+		{name: "ID token", header: "Authorization", value: "Bearer " + j.token(t, map[string]any{"email": "Editor@Lehigh.edu"}), identity: Identity{Name: "editor@lehigh.edu", Method: "google", Role: RoleEditor}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Identity
+			handler := RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = IdentityFromContext(r.Context())
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(tt.header, tt.value)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if got != tt.identity {
+				t.Fatalf("expected identity %+v, got %+v", tt.identity, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Why a transform created a term, or that it deleted it again after failing.
+const (
+	termReasonNotFound     = "not-found"
+	termReasonNameMismatch = "name-mismatch"
+	termReasonRolledBack   = "rolled-back"
+)
+
+// termAuditEntry records a taxonomy term created while resolving contributors.
+type termAuditEntry struct {
+	Time     time.Time `json:"time"`
+	Identity string    `json:"identity"`
+	// Sheet is whatever the caller passed as ?sheet=, usually the Google
+	// Sheet's URL.
+	Sheet  string `json:"sheet,omitempty"`
+	Vocab  string `json:"vocab"`
+	Name   string `json:"name"`
+	Email  string `json:"email,omitempty"`
+	Orcid  string `json:"orcid,omitempty"`
+	Tid    int    `json:"tid"`
+	Reason string `json:"reason"`
+	Ror    string `json:"ror,omitempty"`
+}
+
+// auditLog appends JSON lines to a file that is never truncated.
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+var activeAuditLog *auditLog
+
+// ConfigureAuditLog appends a JSON line for every term transforms create to
+// the file at path. An empty path only reports created terms in the ZIP. It
+// should be called once at startup.
+func ConfigureAuditLog(path string) error {
+	if path == "" {
+		activeAuditLog = nil
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("unable to open audit log %s: %w", path, err)
+	}
+	activeAuditLog = &auditLog{file: file}
+
+	return nil
+}
+
+func (a *auditLog) record(entry termAuditEntry) error {
+	if a == nil {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.file.Write(append(line, '\n'))
+	return err
+}
+
+// termsCreatedCSVName lists the terms a transform created, for catalogers to
+// review.
+const termsCreatedCSVName = "terms-created.csv"
+
+func writeTermsCreatedCSV(out io.Writer, entries []termAuditEntry) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"time", "identity", "sheet", "vocab", "name", "email", "orcid", "tid", "reason", "ror"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		record := []string{e.Time.Format(time.RFC3339), e.Identity, e.Sheet, e.Vocab, e.Name, e.Email, e.Orcid, strconv.Itoa(e.Tid), e.Reason, e.Ror}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
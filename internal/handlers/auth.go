@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/httprc/v3"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	jwt "github.com/lestrrat-go/jwx/v3/jwt"
+)
+
+const googleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// jwksFetchTimeout bounds how long a request waits on the first fetch of the
+// key set, or a refetch after the cached copy could not be refreshed.
+const jwksFetchTimeout = 5 * time.Second
+
+// jwksProvider keeps the key set Google signs ID tokens with. Keys are
+// fetched once and refreshed in the background on the schedule the JWKS
+// response's Cache-Control header asks for, so rotated keys are picked up
+// without a round-trip per request.
+type jwksProvider struct {
+	url string
+
+	once  sync.Once
+	cache *jwk.Cache
+	err   error
+}
+
+var activeJWKS = newJWKSProvider(googleCertsURL)
+
+func newJWKSProvider(url string) *jwksProvider {
+	return &jwksProvider{url: url}
+}
+
+// ConfigureJWKS sets where ID token signing keys are fetched from. An empty
+// url keeps Google's. It should be called once at startup.
+func ConfigureJWKS(url string) {
+	if url == "" {
+		return
+	}
+	activeJWKS = newJWKSProvider(url)
+}
+
+func (p *jwksProvider) start() {
+	cache, err := jwk.NewCache(context.Background(), httprc.NewClient())
+	if err != nil {
+		p.err = fmt.Errorf("unable to start JWK cache: %w", err)
+		return
+	}
+	// don't block on Google here, keySet fetches on demand if this isn't ready
+	if err := cache.Register(context.Background(), p.url, jwk.WithWaitReady(false)); err != nil {
+		p.err = fmt.Errorf("unable to register JWK set %s: %w", p.url, err)
+		return
+	}
+	p.cache = cache
+}
+
+// keySet returns the cached key set, fetching it if it has never been loaded.
+// It fails closed: without keys no token is accepted.
+func (p *jwksProvider) keySet(ctx context.Context) (jwk.Set, error) {
+	p.once.Do(p.start)
+	if p.err != nil {
+		return nil, p.err
+	}
+	if set, err := p.cache.Lookup(ctx, p.url); err == nil {
+		return set, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+	set, err := p.cache.Refresh(ctx, p.url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch JWK set %s: %w", p.url, err)
+	}
+	return set, nil
+}
+
+// Identity is who a request was made by.
+type Identity struct {
+	// Name is the API key's name or the signed in user's email.
+	Name string
+	// Method is how the request authenticated: "api-key", "google" or "cli".
+	Method string
+	Role   Role
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx that carries id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity RequireAuth (or the CLI) attached
+// to ctx.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// RequireAuth only lets requests through to next that carry an API key or a
+// Google ID token the auth policy allows, with the caller's Identity in the
+// request context. Every route that reads sheet data or writes to Drupal
+// should be wrapped in it.
+func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := authRequest(w, r)
+		if !ok {
+			return
+		}
+		slog.Info("Authenticated request", "identity", id.Name, "auth", id.Method, "role", id.Role, "method", r.Method, "path", r.URL.Path)
+		next(w, r.WithContext(WithIdentity(r.Context(), id)))
+	}
+}
+
+func authRequest(w http.ResponseWriter, r *http.Request) (Identity, bool) {
+	if key, ok := matchAPIKey(r.Header.Get("X-Secret")); ok {
+		return Identity{Name: key.Name, Method: "api-key", Role: key.Role}, true
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		slog.Error("Authorization header missing")
+		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
+		return Identity{}, false
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		slog.Error("Token not found")
+		http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
+		return Identity{}, false
+	}
+
+	tokenString := parts[1]
+
+	keySet, err := activeJWKS.keySet(r.Context())
+	if err != nil {
+		slog.Error("unable to get JWK set", "err", err)
+		http.Error(w, "Unable to verify tokens right now, try again later", http.StatusServiceUnavailable)
+		return Identity{}, false
+	}
+
+	token, err := jwt.Parse([]byte(tokenString),
+		jwt.WithKeySet(keySet),
+		jwt.WithValidate(true),
+	)
+	if err != nil {
+		slog.Error("unable to parse token", "err", err)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return Identity{}, false
+	}
+
+	policy := activeAuthPolicy
+	if err := policy.checkToken(token); err != nil {
+		slog.Error("Token rejected by auth policy", "err", err)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return Identity{}, false
+	}
+
+	var emailVerified bool
+	err = token.Get("email_verified", &emailVerified)
+	if err != nil || !emailVerified {
+		slog.Error("Unverified email", "err", err)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return Identity{}, false
+	}
+
+	var email string
+	err = token.Get("email", &email)
+	if err != nil {
+		slog.Error("No email claim", "err", err)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return Identity{}, false
+	}
+	if !policy.allowsEmail(email) {
+		slog.Error("Email not allowed by auth policy", "email", email)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return Identity{}, false
+	}
+
+	email = strings.ToLower(email)
+	return Identity{Name: email, Method: "google", Role: policy.roleFor(email)}, true
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	jwt "github.com/lestrrat-go/jwx/v3/jwt"
+)
+
+// testJWKS stands in for Google's certs endpoint and signs ID tokens with the
+// key it publishes.
+type testJWKS struct {
+	server   *httptest.Server
+	key      jwk.Key
+	requests atomic.Int32
+	down     atomic.Bool
+}
+
+// useTestJWKS points authRequest at a local JWKS for the rest of the test.
+func useTestJWKS(t *testing.T) *testJWKS {
+	t.Helper()
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed generating key: %v", err)
+	}
+	key, err := jwk.Import(raw)
+	if err != nil {
+		t.Fatalf("failed importing key: %v", err)
+	}
+	_ = key.Set(jwk.KeyIDKey, "test")
+	_ = key.Set(jwk.AlgorithmKey, jwa.RS256())
+	public, err := key.PublicKey()
+	if err != nil {
+		t.Fatalf("failed getting public key: %v", err)
+	}
+	set := jwk.NewSet()
+	_ = set.AddKey(public)
+
+	j := &testJWKS{key: key}
+	j.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		j.requests.Add(1)
+		if j.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+
+	original, originalPolicy := activeJWKS, activeAuthPolicy
+	ConfigureJWKS(j.server.URL)
+	activeAuthPolicy.Audiences = []string{"test-client"}
+	t.Cleanup(func() {
+		activeJWKS, activeAuthPolicy = original, originalPolicy
+		j.server.Close()
+	})
+	return j
+}
+
+// token returns a signed ID token with the given claims on top of a valid,
+// verified Lehigh email issued by Google for test-client.
+func (j *testJWKS) token(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	tok := jwt.New()
+	_ = tok.Set(jwt.ExpirationKey, time.Now().Add(time.Hour))
+	_ = tok.Set(jwt.IssuerKey, "https://accounts.google.com")
+	_ = tok.Set(jwt.AudienceKey, "test-client")
+	_ = tok.Set("email", "editor@lehigh.edu")
+	_ = tok.Set("email_verified", true)
+	for k, v := range claims {
+		_ = tok.Set(k, v)
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256(), j.key))
+	if err != nil {
+		t.Fatalf("failed signing token: %v", err)
+	}
+	return string(signed)
+}
+
+func TestAuthRequestGoogleIDToken(t *testing.T) {
+	os.Setenv("SHARED_SECRET", "foo")
+	j := useTestJWKS(t)
+
+	other := useTestJWKS(t)
+	forged := other.token(t, nil)
+	ConfigureJWKS(j.server.URL)
+
+	tests := []struct {
+		name       string
+		header     string
+		statusCode int
+	}{
+		{name: "valid token", header: "Bearer " + j.token(t, nil), statusCode: http.StatusOK},
+		{name: "signed by another key", header: "Bearer " + forged, statusCode: http.StatusUnauthorized},
+		{name: "garbage", header: "Bearer not-a-token", statusCode: http.StatusUnauthorized},
+		{name: "expired", header: "Bearer " + j.token(t, map[string]any{jwt.ExpirationKey: time.Now().Add(-time.Hour)}), statusCode: http.StatusUnauthorized},
+		{name: "unverified email", header: "Bearer " + j.token(t, map[string]any{"email_verified": false}), statusCode: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Basic abc", statusCode: http.StatusUnauthorized},
+	}
+
+	// warm the cache, after that no request should reach the JWKS
+	if _, err := activeJWKS.keySet(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fetched := j.requests.Load()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", tt.header)
+			rec := httptest.NewRecorder()
+			if _, ok := authRequest(rec, req); ok {
+				rec.WriteHeader(http.StatusOK)
+			}
+			if rec.Code != tt.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	if got := j.requests.Load(); got != fetched {
+		t.Fatalf("expected the cached JWKS to be reused, got %d more fetches", got-fetched)
+	}
+}
+
+func TestAuthRequestFailsClosedWithoutKeys(t *testing.T) {
+	os.Setenv("SHARED_SECRET", "foo")
+	j := useTestJWKS(t)
+	token := j.token(t, nil)
+	j.down.Store(true)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	if _, ok := authRequest(rec, req); ok {
+		t.Fatal("expected the request to be rejected")
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", rec.Code)
+	}
+
+	// once Google is back the next request gets through
+	j.down.Store(false)
+	rec = httptest.NewRecorder()
+	if _, ok := authRequest(rec, req); !ok {
+		t.Fatalf("expected the request to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuthRequestPolicy(t *testing.T) {
+	os.Setenv("SHARED_SECRET", "foo")
+	j := useTestJWKS(t)
+
+	original := activeAuthPolicy
+	defer func() {
+		activeAuthPolicy = original
+	}()
+	path := filepath.Join(t.TempDir(), "auth.json")
+	policy := `{
+  "domains": ["@Lehigh.edu", "example.org"],
+  "audiences": ["test-client"],
+  "issuers": ["https://accounts.google.com"],
+  "deny": ["Former@lehigh.edu"]
+}`
+	if err := os.WriteFile(path, []byte(policy), 0644); err != nil {
+		t.Fatalf("failed writing policy: %v", err)
+	}
+	if err := LoadAuthPolicy(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		claims     map[string]any
+		statusCode int
+	}{
+		{name: "allowed", statusCode: http.StatusOK},
+		{name: "second domain", claims: map[string]any{"email": "someone@example.org"}, statusCode: http.StatusOK},
+		{name: "other domain", claims: map[string]any{"email": "someone@gmail.com"}, statusCode: http.StatusForbidden},
+		{name: "lookalike domain", claims: map[string]any{"email": "someone@notlehigh.edu"}, statusCode: http.StatusForbidden},
+		{name: "denied user", claims: map[string]any{"email": "former@lehigh.edu"}, statusCode: http.StatusForbidden},
+		{name: "minted for another client", claims: map[string]any{jwt.AudienceKey: "someone-else"}, statusCode: http.StatusUnauthorized},
+		{name: "other issuer", claims: map[string]any{jwt.IssuerKey: "https://evil.example"}, statusCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+j.token(t, tt.claims))
+			rec := httptest.NewRecorder()
+			if _, ok := authRequest(rec, req); ok {
+				rec.WriteHeader(http.StatusOK)
+			}
+			if rec.Code != tt.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	// without audiences no Google ID token is accepted
+	activeAuthPolicy.Audiences = nil
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+j.token(t, nil))
+	rec := httptest.NewRecorder()
+	if _, ok := authRequest(rec, req); ok || rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without audiences, got %d", rec.Code)
+	}
+
+	// an allow list narrows sign in to just those people
+	activeAuthPolicy.Allow = []string{"editor@lehigh.edu"}
+	for email, allowed := range map[string]bool{"Editor@lehigh.edu": true, "other@lehigh.edu": false} {
+		if got := activeAuthPolicy.allowsEmail(email); got != allowed {
+			t.Fatalf("expected allowsEmail(%q) = %v", email, allowed)
+		}
+	}
+}
+
+func TestParseAuthPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{name: "default", raw: string(defaultAuthPolicy)},
+		{name: "missing domains", raw: `{"issuers":["https://accounts.google.com"]}`, wantErr: "domains is required"},
+		{name: "missing issuers", raw: `{"domains":["lehigh.edu"]}`, wantErr: "issuers is required"},
+		{name: "bad json", raw: `{`, wantErr: "unexpected end of JSON input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAuthPolicy([]byte(tt.raw))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	j := useTestJWKS(t)
+
+	tests := []struct {
+		name       string
+		secret     string
+		header     map[string]string
+		statusCode int
+	}{
+		{name: "anonymous", secret: "foo", statusCode: http.StatusUnauthorized},
+		{name: "anonymous with no shared secret configured", secret: "", statusCode: http.StatusUnauthorized},
+		{name: "empty secret header with no shared secret configured", secret: "", header: map[string]string{"X-Secret": ""}, statusCode: http.StatusUnauthorized},
+		{name: "wrong secret", secret: "foo", header: map[string]string{"X-Secret": "bar"}, statusCode: http.StatusUnauthorized},
+		{name: "shared secret", secret: "foo", header: map[string]string{"X-Secret": "foo"}, statusCode: http.StatusTeapot},
+		{name: "ID token", secret: "foo", header: map[string]string{"Authorization": "Bearer " + j.token(t, nil)}, statusCode: http.StatusTeapot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SHARED_SECRET", tt.secret)
+			called := false
+			handler := RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusTeapot)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/workbench/transform", strings.NewReader("Title\nfoo\n"))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+			if called != (tt.statusCode == http.StatusTeapot) {
+				t.Fatalf("expected handler called = %v", !called)
+			}
+		})
+	}
+}
@@ -1,37 +1,22 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/lehigh-university-libraries/fabricator/internal/contributor"
-	"github.com/lehigh-university-libraries/fabricator/internal/tgn"
-	"github.com/lestrrat-go/jwx/v3/jwk"
-	jwt "github.com/lestrrat-go/jwx/v3/jwt"
-	edtf "github.com/sfomuseum/go-edtf/parser"
 )
 
-const googleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
-
 func CheckMyWork(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if !authRequest(w, r) {
-		return
-	}
 
 	if r.ContentLength == 0 {
 		http.Error(w, "Request body is empty", http.StatusBadRequest)
@@ -48,198 +33,67 @@ func CheckMyWork(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	errors := map[string]string{}
+	report := newCheckReport()
 	if len(csvData) < 2 {
-		errorColumn := numberToExcelColumn(0)
-		errors[errorColumn] = "No rows in CSV to process"
+		report.add(-1, 0, "", noRowsRule, "No rows in CSV to process")
 		csvData = append(csvData, []string{})
 	}
 
-	relators := validRelators()
-
 	header := csvData[0]
-	doiPattern := regexp.MustCompile(`^10\.\d{4,9}\/[-._;()/:A-Za-z0-9]+$`)
-	datePattern := regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?$`)
-	hierarchyChecked := map[string]bool{}
-	requiredFields := []string{
-		"Title",
-		"Object Model",
-		"Full Title",
-	}
-	urlCheckCache := &sync.Map{}
-	uploadIds := map[string]bool{}
+	rules := activeCheckRules
+	state := newCheckState(r.Context(), header)
+	state.uploadIds = sheetUploadIDs(header, csvData[1:])
 	for rowIndex, row := range csvData[1:] {
+		cr := checkRow{checkState: state, row: row}
 		for colIndex, col := range row {
 			if colIndex >= len(header) {
-				c := numberToExcelColumn(colIndex)
-				i := c + strconv.Itoa(rowIndex+2)
-				errors[i] = "Row has more columns than the header"
+				report.add(rowIndex, colIndex, "", rowTooWideRule, "Row has more columns than the header")
 				continue
 			}
 
 			column := header[colIndex]
-			c := numberToExcelColumn(colIndex)
-			i := c + strconv.Itoa(rowIndex+2)
 			if col == "" {
-				// require fields on create
-				if strInSlice(column, requiredFields) && ColumnValue("Node ID", header, row) == "" {
-					errors[i] = "Missing value"
-				}
-				if column == "Parent Collection" {
-					model := ColumnValue("Object Model", header, row)
-					if model == "Paged Content" && ColumnValue("Page/Item Parent ID", header, row) == "" {
-						errors[i] = "Paged content must have a parent collection or parent ID"
-					}
-				}
-
-				if column == "Page/Item Parent ID" {
-					model := ColumnValue("Object Model", header, row)
-					if model == "Page" && ColumnValue("Parent Collection", header, row) == "" {
-						errors[i] = "Pages must have a parent id or parent collection"
+				for _, rule := range rules.blank[column] {
+					if msg := rule.Validate("", cr); msg != "" {
+						report.add(rowIndex, colIndex, column, rule.checkRule, msg)
 					}
 				}
-
-				if column == "Resource Type" {
-					model := ColumnValue("Object Model", header, row)
-					if model != "Page" {
-						errors[i] = "Must have a resource type"
-					}
-				}
-
 				continue
 			}
 
+			var values []string
 			for _, cell := range strings.Split(col, " ; ") {
-				cell = strings.TrimSpace(cell)
-				if cell == "" {
-					continue
+				if cell = strings.TrimSpace(cell); cell != "" {
+					values = append(values, cell)
 				}
-
-				switch column {
-				// make sure these columns are integers
-				case "Parent Collection", "PPI", "Node ID":
-					id, err := strconv.Atoi(cell)
-					if err != nil {
-						errors[i] = "Must be an integer"
-						break
-					}
-					if column == "Parent Collection" {
-						url := fmt.Sprintf("%s/node/%d?_format=json", os.Getenv("ISLE_SITE_URL"), id)
-						if !checkURL(url, urlCheckCache) {
-							errors[i] = fmt.Sprintf("Could not identify parent collection %d", id)
-						}
-					}
-					if column == "Node ID" {
-						url := fmt.Sprintf("%s/node/%d?_format=json", os.Getenv("ISLE_SITE_URL"), id)
-						if !checkURL(url, urlCheckCache) {
-							errors[i] = fmt.Sprintf("Could not find node ID %d", id)
-						}
-					}
-					// make sure these columns are valid URLs
-				case "Catalog or ArchivesSpace URL":
-					parsedURL, err := url.ParseRequestURI(cell)
-					if err != nil || parsedURL.Scheme == "" && parsedURL.Host == "" {
-						errors[i] = "Invalid URL"
-					}
-				// make sure each upload ID is unique
-				case "Upload ID":
-					if _, exists := uploadIds[cell]; exists {
-						errors[i] = "Duplicate upload ID"
-					}
-					uploadIds[cell] = true
-				// check for valid EDTF values
-				case "Creation Date", "Date Captured", "Embargo Until Date":
-					if !datePattern.MatchString(cell) && !edtf.IsValid(cell) {
-						errors[i] = "Invalid EDTF value"
-						slog.Error("Invalid EDTF value", "cell", cell)
-					}
-				// check for valid DOI value
-				case "DOI":
-					if !doiPattern.MatchString(cell) {
-						errors[i] = "Invalid DOI"
-					}
-				case "Rights Statement":
-					if _, ok := rightsStatementURI(cell); !ok {
-						errors[i] = "Invalid Rights Statement"
-					}
-				// make sure the parent ID matches an upload ID in the spreadsheet
-				case "Page/Item Parent ID":
-					if _, ok := uploadIds[cell]; !ok {
-						errors[i] = "Unknown parent ID"
-						break
-					}
-					id := ColumnValue("Upload ID", header, row)
-					if cell == id {
-						errors[i] = "Upload ID and parent ID can not be equal"
-					}
-				case "Contributor":
-					var c contributor.Contributor
-					err := json.Unmarshal([]byte(cell), &c)
-					if err != nil {
-						errors[i] = "Contributor not in proper format"
-					}
-					name := strings.Split(c.Name, ":")
-					if len(name) < 4 {
-						errors[i] = "Contributor name not in proper format"
-						break
-					}
-					if c.Status != "" || c.Email != "" || c.Institution != "" || c.Orcid != "" {
-						if name[2] != "person" {
-							errors[i] = "Additional fields can only be applied to people"
-						}
-					}
-					if !strInSlice(name[2], []string{"person", "corporate_body"}) {
-						errors[i] = fmt.Sprintf("Bad vocabulary ID for contributor: %s", name[2])
-					}
-					relator := fmt.Sprintf("%s:%s", name[0], name[1])
-					if !strInSlice(relator, relators) {
-						errors[i] = fmt.Sprintf("Invalid relator: %s", relator)
-					}
-					if name[3] == "" {
-						errors[i] = "Blank names are not allowed"
-					}
-
-					// make sure the file exists in the filesystem
-				case "File Path", "Supplemental File":
-					filename := workbenchMediaPath(cell)
-					if !fileExists(filename) {
-						errors[i] = "File does not exist in islandora_staging"
-						break
-					}
-
-					if column == "File Path" {
-						model := strings.TrimSpace(ColumnValue("Object Model", header, row))
-						if model != "" {
-							mediaType := workbenchMediaType(model)
-							if !isAllowedWorkbenchMediaExtension(filename, mediaType) {
-								errors[i] = fmt.Sprintf("File extension is not allowed for object model %s", mediaType)
-							}
+			}
+			for i, cell := range values {
+				// only point at the failing value when there is more than one
+				value, position := "", 0
+				if len(values) > 1 {
+					value, position = cell, i+1
+				}
+				for _, rule := range rules.values[column] {
+					for _, msg := range rule.messages(cell, cr) {
+						f := report.addValue(rowIndex, colIndex, column, rule.checkRule, msg, value, position)
+						if c, ok := rule.Validator.(candidateValidator); ok && f != nil {
+							f.Candidates = c.Candidates(cell, cr)
 						}
 					}
-				case "Add Coverpage (Y/N)", "Make Public (Y/N)":
-					if cell != "Yes" && cell != "No" {
-						errors[i] = "Invalid value. Must be Yes or No"
-					}
-				case "Hierarchical Geographic (Getty TGN)":
-					if hierarchyChecked[cell] {
-						break
-					}
-					hierarchyChecked[cell] = true
-					_, err := tgn.GetLocationFromTGN(cell)
-					if err != nil {
-						errors[i] = "Unable to get TGN"
-					}
-				case "Title":
-					if len(cell) > 255 {
-						errors[i] = "Title is longer than 255 characters"
-					}
 				}
 			}
 		}
 	}
 
+	checkHierarchy(header, csvData[1:], report)
+
+	var payload any = report.response()
+	if wantsLegacyCheckResponse(r) {
+		payload = report.legacy()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	jsonResponse, err := json.Marshal(errors)
+	jsonResponse, err := json.Marshal(payload)
 	if err != nil {
 		slog.Error("Error creating JSON response", "err", err)
 		http.Error(w, "Error creating JSON response", http.StatusInternalServerError)
@@ -387,69 +241,6 @@ func fileExists(filename string) bool {
 	return mode&0004 != 0
 }
 
-func authRequest(w http.ResponseWriter, r *http.Request) bool {
-	secret := r.Header.Get("X-Secret")
-	if secret == os.Getenv("SHARED_SECRET") {
-		return true
-	}
-
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		slog.Error("Authorization header missing")
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return false
-	}
-
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-		slog.Error("Token not found")
-		http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
-		return false
-	}
-
-	tokenString := parts[1]
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	keySet, err := jwk.Fetch(ctx, googleCertsURL)
-	if err != nil {
-		slog.Error("unable to fetch JWK set", "url", googleCertsURL, "err", err)
-	}
-
-	token, err := jwt.Parse([]byte(tokenString),
-		jwt.WithKeySet(keySet),
-		jwt.WithValidate(true),
-		jwt.WithContext(ctx),
-	)
-	if err != nil {
-		slog.Error("unable to parse token", "err", err)
-		return false
-	}
-
-	var emailVerified bool
-	err = token.Get("email_verified", &emailVerified)
-	if err != nil || !emailVerified {
-		slog.Error("Unverified email", "err", err)
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return false
-	}
-
-	var email string
-	err = token.Get("email", &email)
-	if err != nil {
-		slog.Error("No email claim", "err", err)
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return false
-	}
-	if len(email) < 11 || email[len(email)-11:] != "@lehigh.edu" {
-		slog.Error("Unknown email", "email", email)
-		http.Error(w, "Error extracting email from token", http.StatusInternalServerError)
-		return false
-	}
-
-	return true
-}
-
 func IndexOf(value string, slice []string) int {
 	for i, v := range slice {
 		if v == value {
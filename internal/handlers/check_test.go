@@ -458,6 +458,17 @@ func TestCheckMyWork(t *testing.T) {
 			statusCode: http.StatusOK,
 			response:   `{"D2":"Unknown parent ID"}`,
 		},
+		{
+			name:   "Parent ID defined later in the sheet",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Page/Item Parent ID", "Upload ID"},
+				{"foo", "Image", "foo", "456", "123"},
+				{"foo", "Compound Object", "foo", "", "456"},
+			},
+			statusCode: http.StatusOK,
+			response:   `{}`,
+		},
 		{
 			name:   "Upload ID equals Parent ID",
 			method: http.MethodPost,
@@ -497,7 +508,117 @@ func TestCheckMyWork(t *testing.T) {
 				{"foo", "bar", "foo", `{"name":"rel:cre:person:"}`},
 			},
 			statusCode: http.StatusOK,
-			response:   `{"D2":"Blank names are not allowed"}`,
+			response:   `{"D2":"Blank names are not allowed; Invalid relator: rel:cre"}`,
+		},
+		{
+			name:   "Contributor not JSON and bad name",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `relators:cre:person:Smith`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{"D2":"Contributor not in proper format; Contributor name not in proper format"}`,
+		},
+		{
+			name:   "Contributor every bad value in a multi-value cell",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"name":"rel:foo:person:bar"} ; {"name":"relators:cre:person:Smith"} ; {"name":"relators:cre:place:"}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{"D2":"Invalid relator: rel:foo ({\"name\":\"rel:foo:person:bar\"}); Blank names are not allowed ({\"name\":\"relators:cre:place:\"}); Bad vocabulary ID for contributor: place ({\"name\":\"relators:cre:place:\"})"}`,
+		},
+		{
+			name:   "Contributor name with a colon",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"name":"relators:cre:person::Smith"} ; {"name":"relators:cre:corporate_body:Lehigh University: Library"}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{}`,
+		},
+		{
+			name:   "Contributor relator and vocab keys",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"relator":"cre","vocab":"person","name":"Smith: Sam"} ; {"relator":"rel:foo","vocab":"place","name":" "}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{"D2":"Blank names are not allowed ({\"relator\":\"rel:foo\",\"vocab\":\"place\",\"name\":\" \"}); Invalid relator: rel:foo ({\"relator\":\"rel:foo\",\"vocab\":\"place\",\"name\":\" \"}); Bad vocabulary ID for contributor: place ({\"relator\":\"rel:foo\",\"vocab\":\"place\",\"name\":\" \"})"}`,
+		},
+		{
+			name:   "Contributor relator without vocab",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"relator":"relators:cre","name":"Smith"}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{"D2":"Contributor relator and vocab must be given together"}`,
+		},
+		{
+			name:   "Contributor ORCiD",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"name":"relators:cre:person:Smith","orcid":"https://orcid.org/0000-0002-1825-0097"}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{}`,
+		},
+		{
+			name:   "Contributor ORCiD bad check digit",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"name":"relators:cre:person:Smith","orcid":"0000-0002-1825-0098"}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{"D2":"ORCiD check digit is wrong, check for a typo: 0000-0002-1825-0098"}`,
+		},
+		{
+			name:   "Contributor ORCiD not an iD",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"name":"relators:cre:person:Smith","orcid":"orcid.org/jsmith"}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{"D2":"Invalid ORCiD, expected 0000-0000-0000-0000 or https://orcid.org/0000-0000-0000-0000: orcid.org/jsmith"}`,
+		},
+		{
+			name:   "Contributor institution ROR ID",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"name":"relators:cre:person:Smith","institution":"Lehigh University","institution_ror":"https://ror.org/012afjb06"}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{}`,
+		},
+		{
+			name:   "Contributor institution ROR ID bad checksum",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"name":"relators:cre:person:Smith","institution":"Lehigh University","institution_ror":"012afjb07"}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{"D2":"ROR ID checksum is wrong, check for a typo: 012afjb07"}`,
+		},
+		{
+			name:   "Contributor institution ROR ID not an ID and no institution",
+			method: http.MethodPost,
+			body: [][]string{
+				{"Title", "Object Model", "Full Title", "Contributor"},
+				{"foo", "bar", "foo", `{"name":"relators:cre:person:Smith","institution_ror":"lehigh"}`},
+			},
+			statusCode: http.StatusOK,
+			response:   `{"D2":"Invalid ROR ID, expected 0xxxxxx00 or https://ror.org/0xxxxxx00: lehigh; A ROR ID needs the institution's name too"}`,
 		},
 		{
 			name:   "Paged Content need collection",
@@ -566,7 +687,7 @@ func TestCheckMyWork(t *testing.T) {
 				reqBody = bytes.NewBuffer(nil)
 			}
 
-			req := httptest.NewRequest(tt.method, "/check-my-work", reqBody)
+			req := httptest.NewRequest(tt.method, "/check-my-work?format=legacy", reqBody)
 			if tt.name == "Not authorized" {
 				req.Header.Set("X-Secret", "nope")
 			} else {
@@ -574,8 +695,8 @@ func TestCheckMyWork(t *testing.T) {
 			}
 			rec := httptest.NewRecorder()
 
-			// Call the handler
-			CheckMyWork(rec, req)
+			// Call the handler the way main routes it
+			RequireAuth(CheckMyWork)(rec, req)
 
 			// Assert response status code
 			if rec.Code != tt.statusCode {
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// columns.json is the default sheet header -> workbench column mapping. It can be
+// replaced at startup with FABRICATOR_COLUMN_MAPPING so new template columns do not
+// need a code change.
+//
+//go:embed columns.json
+var defaultColumnMapping []byte
+
+const (
+	columnKindText        = "text"
+	columnKindAttr0JSON   = "attr0-json"
+	columnKindJSON        = "json"
+	columnKindVidPrefix   = "vid-prefix"
+	columnKindBoolean     = "boolean"
+	columnKindFlag        = "flag"
+	columnKindInteger     = "integer"
+	columnKindDigits      = "digits"
+	columnKindRightsURI   = "rights-uri"
+	columnKindTGN         = "tgn"
+	columnKindContributor = "contributor"
+	columnKindFilePath    = "file-path"
+)
+
+var digitsPattern = regexp.MustCompile(`^\d+$`)
+
+// columnMapping declares how a single Google Sheet header is turned into a
+// workbench CSV column.
+type columnMapping struct {
+	// Header is the human friendly label in row one of the sheet.
+	Header string `json:"header"`
+	// Field is the workbench CSV column the values are written to.
+	Field string `json:"field"`
+	// Kind selects the value transform, defaulting to text.
+	Kind string `json:"kind,omitempty"`
+
+	// Attr0 is the typed attribute for attr0-json columns, e.g. field_note's "box".
+	Attr0 string `json:"attr0,omitempty"`
+	// Attr0Key overrides the JSON key Attr0 is stored under (default "attr0").
+	Attr0Key string `json:"attr0_key,omitempty"`
+	// ValueKey overrides the JSON key the cell value is stored under for
+	// attr0-json (default "value") and json columns.
+	ValueKey string `json:"value_key,omitempty"`
+	// Extra holds constant keys merged into json column payloads.
+	Extra map[string]string `json:"extra,omitempty"`
+	// Vid is the vocabulary prepended to vid-prefix values.
+	Vid string `json:"vid,omitempty"`
+	// TrueValues are the cell values a flag column treats as "1".
+	TrueValues []string `json:"true_values,omitempty"`
+}
+
+// columnMappings indexes mappings by their sheet header.
+type columnMappings map[string]columnMapping
+
+var activeColumnMappings = mustParseColumnMappings(defaultColumnMapping)
+
+// LoadColumnMappings replaces the built-in column mapping with the JSON file at path.
+// An empty path keeps the defaults. It should be called once at startup before
+// any transform is served.
+func LoadColumnMappings(path string) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read column mapping %s: %w", path, err)
+	}
+	mappings, err := parseColumnMappings(raw)
+	if err != nil {
+		return fmt.Errorf("invalid column mapping %s: %w", path, err)
+	}
+	activeColumnMappings = mappings
+
+	return nil
+}
+
+func mustParseColumnMappings(raw []byte) columnMappings {
+	mappings, err := parseColumnMappings(raw)
+	if err != nil {
+		panic(err)
+	}
+	return mappings
+}
+
+func parseColumnMappings(raw []byte) (columnMappings, error) {
+	var list []columnMapping
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+
+	mappings := columnMappings{}
+	for i, m := range list {
+		m.Header = strings.TrimSpace(m.Header)
+		if m.Kind == "" {
+			m.Kind = columnKindText
+		}
+		if err := m.validate(); err != nil {
+			return nil, fmt.Errorf("mapping %d (%q): %w", i, m.Header, err)
+		}
+		if _, exists := mappings[m.Header]; exists {
+			return nil, fmt.Errorf("mapping %d: duplicate header %q", i, m.Header)
+		}
+		mappings[m.Header] = m
+	}
+
+	return mappings, nil
+}
+
+func (m columnMapping) validate() error {
+	if m.Header == "" {
+		return fmt.Errorf("header is required")
+	}
+	if m.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+
+	switch m.Kind {
+	case columnKindText,
+		columnKindBoolean,
+		columnKindInteger,
+		columnKindDigits,
+		columnKindRightsURI,
+		columnKindTGN,
+		columnKindContributor,
+		columnKindFilePath:
+	case columnKindAttr0JSON:
+		if m.Attr0 == "" {
+			return fmt.Errorf("attr0 is required for %s columns", m.Kind)
+		}
+	case columnKindJSON:
+		if m.ValueKey == "" {
+			return fmt.Errorf("value_key is required for %s columns", m.Kind)
+		}
+	case columnKindVidPrefix:
+		if m.Vid == "" {
+			return fmt.Errorf("vid is required for %s columns", m.Kind)
+		}
+	case columnKindFlag:
+		if len(m.TrueValues) == 0 {
+			return fmt.Errorf("true_values is required for %s columns", m.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", m.Kind)
+	}
+
+	return nil
+}
+
+// transformValue applies the mapping's value transform to a single
+// (already " ; " split) cell value. Kinds that need request scoped state,
+// like tgn and contributor, are handled by the caller.
+func (m columnMapping) transformValue(str string) (string, error) {
+	switch m.Kind {
+	case columnKindBoolean:
+		switch str {
+		case "Yes":
+			return "1", nil
+		case "No":
+			return "0", nil
+		}
+		return "", fmt.Errorf("unknown %s: %s", m.Header, str)
+	case columnKindFlag:
+		if strInSlice(str, m.TrueValues) {
+			return "1", nil
+		}
+		return "0", nil
+	case columnKindDigits:
+		if !digitsPattern.MatchString(str) {
+			return "", fmt.Errorf("unknown %s: %s", m.Header, str)
+		}
+	case columnKindInteger:
+		if _, err := strconv.Atoi(str); err != nil {
+			return "", fmt.Errorf("unknown %s: %s", m.Header, str)
+		}
+		return strings.TrimLeft(str, "0"), nil
+	case columnKindRightsURI:
+		uri, ok := rightsStatementURI(str)
+		if !ok {
+			return "", fmt.Errorf("unknown %s: %s", m.Header, str)
+		}
+		return uri, nil
+	case columnKindAttr0JSON:
+		valueKey, attr0Key := m.ValueKey, m.Attr0Key
+		if valueKey == "" {
+			valueKey = "value"
+		}
+		if attr0Key == "" {
+			attr0Key = "attr0"
+		}
+		return encodeColumnJSON(m, map[string]string{valueKey: str, attr0Key: m.Attr0})
+	case columnKindJSON:
+		payload := map[string]string{}
+		for k, v := range m.Extra {
+			payload[k] = v
+		}
+		payload[m.ValueKey] = str
+		return encodeColumnJSON(m, payload)
+	case columnKindVidPrefix:
+		return fmt.Sprintf("%s:%s", m.Vid, str), nil
+	case columnKindFilePath:
+		str = strings.ReplaceAll(str, `\`, `/`)
+		if len(str) > 7 && str[0:6] == "/home/" {
+			return str, nil
+		}
+		str = strings.TrimLeft(str, "/")
+		if len(str) > 3 && str[0:3] != "mnt" {
+			str = fmt.Sprintf("/mnt/islandora_staging/%s", str)
+		}
+	}
+
+	return str, nil
+}
+
+func encodeColumnJSON(m columnMapping, payload map[string]string) (string, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("error encoding %s: %v", m.Header, err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseColumnMappings(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{
+			name: "valid mapping defaults kind to text",
+			raw:  `[{"header":"Title","field":"title"}]`,
+		},
+		{
+			name:    "missing field",
+			raw:     `[{"header":"Title"}]`,
+			wantErr: "field is required",
+		},
+		{
+			name:    "unknown kind",
+			raw:     `[{"header":"Title","field":"title","kind":"nope"}]`,
+			wantErr: `unknown kind "nope"`,
+		},
+		{
+			name:    "attr0-json requires attr0",
+			raw:     `[{"header":"Box","field":"field_note","kind":"attr0-json"}]`,
+			wantErr: "attr0 is required",
+		},
+		{
+			name:    "vid-prefix requires vid",
+			raw:     `[{"header":"Place","field":"field_geographic_subject","kind":"vid-prefix"}]`,
+			wantErr: "vid is required",
+		},
+		{
+			name:    "duplicate header",
+			raw:     `[{"header":"Title","field":"title"},{"header":"Title","field":"field_full_title"}]`,
+			wantErr: "duplicate header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mappings, err := parseColumnMappings([]byte(tt.raw))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if mappings["Title"].Kind != columnKindText {
+				t.Fatalf("expected default kind %q, got %q", columnKindText, mappings["Title"].Kind)
+			}
+		})
+	}
+}
+
+func TestColumnMappingTransformValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapping  columnMapping
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "attr0-json",
+			mapping:  columnMapping{Header: "Archival Box", Field: "field_note", Kind: columnKindAttr0JSON, Attr0: "box"},
+			input:    "12",
+			expected: `{"attr0":"box","value":"12"}`,
+		},
+		{
+			name:     "attr0-json with custom keys",
+			mapping:  columnMapping{Header: "Volume Number", Field: "field_part_detail", Kind: columnKindAttr0JSON, Attr0: "volume", ValueKey: "number", Attr0Key: "type"},
+			input:    "3",
+			expected: `{"number":"3","type":"volume"}`,
+		},
+		{
+			name:     "json with extra keys",
+			mapping:  columnMapping{Header: "Source Publication L-ISSN", Field: "field_related_item", Kind: columnKindJSON, ValueKey: "identifier", Extra: map[string]string{"type": "issn"}},
+			input:    "1234-5678",
+			expected: `{"identifier":"1234-5678","type":"issn"}`,
+		},
+		{
+			name:     "vid-prefix",
+			mapping:  columnMapping{Header: "Subject Geographic (LCNAF)", Field: "field_geographic_subject", Kind: columnKindVidPrefix, Vid: "geographic_naf"},
+			input:    "Coplay (Pa.)",
+			expected: "geographic_naf:Coplay (Pa.)",
+		},
+		{
+			name:     "boolean yes",
+			mapping:  columnMapping{Header: "Make Public (Y/N)", Field: "published", Kind: columnKindBoolean},
+			input:    "Yes",
+			expected: "1",
+		},
+		{
+			name:    "boolean rejects other values",
+			mapping: columnMapping{Header: "Make Public (Y/N)", Field: "published", Kind: columnKindBoolean},
+			input:   "Maybe",
+			wantErr: true,
+		},
+		{
+			name:     "integer trims leading zeros",
+			mapping:  columnMapping{Header: "Child Sort Order", Field: "field_weight", Kind: columnKindInteger},
+			input:    "007",
+			expected: "7",
+		},
+		{
+			name:    "digits rejects non digits",
+			mapping: columnMapping{Header: "Upload ID", Field: "id", Kind: columnKindDigits},
+			input:   "abc",
+			wantErr: true,
+		},
+		{
+			name:     "file path rooted under staging",
+			mapping:  columnMapping{Header: "File Path", Field: "file", Kind: columnKindFilePath},
+			input:    `nested\file.pdf`,
+			expected: "/mnt/islandora_staging/nested/file.pdf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.mapping.transformValue(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Fatalf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadColumnMappingsCustomColumn(t *testing.T) {
+	original := activeColumnMappings
+	defer func() {
+		activeColumnMappings = original
+	}()
+
+	path := filepath.Join(t.TempDir(), "columns.json")
+	mapping := `[
+  {"header": "Title", "field": "title"},
+  {"header": "Shelf Mark", "field": "field_identifier", "kind": "attr0-json", "attr0": "shelf-mark"}
+]`
+	if err := os.WriteFile(path, []byte(mapping), 0644); err != nil {
+		t.Fatalf("failed writing mapping: %v", err)
+	}
+	if err := LoadColumnMappings(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("Title,Shelf Mark,Object Model\nfoo,A-1,Image\n"))
+	headers, rows, err := readCSVWithJSONTags(req, newDrupalTermResolver())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !equalHeaderMaps(headers, map[string]bool{"title": true, "field_identifier": true}) {
+		t.Fatalf("unexpected headers: %#v", headers)
+	}
+	got := rows[0]["field_identifier"]
+	if len(got) != 1 || got[0] != `{"attr0":"shelf-mark","value":"A-1"}` {
+		t.Fatalf("unexpected field_identifier value: %#v", got)
+	}
+}
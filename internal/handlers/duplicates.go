@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lehigh-university-libraries/fabricator/internal/contributor"
+)
+
+// DefaultDuplicateScore is how alike two person names must score, from 0 to
+// 1, for an existing term to be reported as a possible duplicate.
+const DefaultDuplicateScore = 0.8
+
+var duplicateScore = DefaultDuplicateScore
+
+// ConfigureDuplicateMatching sets how alike a new person's name and an
+// existing term's must score to be reported. 0 turns matching off. It should
+// be called once at startup.
+func ConfigureDuplicateMatching(score float64) {
+	duplicateScore = score
+}
+
+// termCandidate is a person term whose name is close to one being created.
+type termCandidate struct {
+	tid  int
+	name string
+}
+
+// possibleDuplicate is a person term a transform created, or would have, next
+// to an existing term with a similar name, for catalogers to review.
+type possibleDuplicate struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Orcid string `json:"orcid,omitempty"`
+	// Term is the new term's ID, placeholder or vocab:name, and empty when
+	// the caller may not create it.
+	Term          string  `json:"term,omitempty"`
+	Candidate     string  `json:"candidate"`
+	CandidateName string  `json:"candidate_name"`
+	Score         float64 `json:"score"`
+
+	tid int
+}
+
+// possibleDuplicatesCSVName is the review report of new person terms that may
+// duplicate existing ones.
+const possibleDuplicatesCSVName = "possible-duplicates.csv"
+
+func writePossibleDuplicatesCSV(out io.Writer, duplicates []possibleDuplicate) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"name", "email", "orcid", "term", "candidate", "candidate_name", "score"}); err != nil {
+		return err
+	}
+	for _, p := range duplicates {
+		record := []string{p.Name, p.Email, p.Orcid, p.Term, p.Candidate, p.CandidateName, strconv.FormatFloat(p.Score, 'f', 2, 64)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// personName is a person's name folded to compare with others: lower case,
+// without diacritics or punctuation, and with the family name and any
+// generational suffix split out.
+type personName struct {
+	family string
+	given  []string
+	suffix string
+}
+
+var diacriticFolder = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "ā", "a", "ă", "a", "ą", "a",
+	"æ", "ae", "ç", "c", "ć", "c", "č", "c", "ď", "d", "đ", "d", "ð", "d",
+	"è", "e", "é", "e", "ê", "e", "ë", "e", "ē", "e", "ė", "e", "ę", "e", "ě", "e",
+	"ğ", "g", "ì", "i", "í", "i", "î", "i", "ï", "i", "ī", "i", "į", "i", "ı", "i",
+	"ł", "l", "ľ", "l", "ñ", "n", "ń", "n", "ň", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "ō", "o", "ő", "o", "œ", "oe",
+	"ř", "r", "ś", "s", "š", "s", "ş", "s", "ß", "ss", "ť", "t", "ţ", "t", "þ", "th",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ū", "u", "ů", "u", "ű", "u",
+	"ý", "y", "ÿ", "y", "ź", "z", "ż", "z", "ž", "z",
+)
+
+// generational suffixes tell a father and son apart, degrees don't tell
+// anyone apart
+var (
+	generationalSuffixes = []string{"jr", "sr", "ii", "iii", "iv"}
+	degreeSuffixes       = []string{"phd", "md"}
+)
+
+// parsePersonName reads "Family, Given Middle, Jr." or "Given Middle Family
+// Jr.".
+func parsePersonName(name string) personName {
+	var p personName
+	folded := diacriticFolder.Replace(strings.ToLower(name))
+	family, given, inverted := strings.Cut(folded, ",")
+	tokens := nameTokens(given)
+	if !inverted {
+		tokens = p.stripSuffixes(nameTokens(family))
+		family = ""
+		if len(tokens) > 0 {
+			family = tokens[len(tokens)-1]
+			tokens = tokens[:len(tokens)-1]
+		}
+	}
+
+	// "Smith-Jones", "Smith Jones" and "O'Brien" are compared run together
+	p.family = strings.Join(nameTokens(family), "")
+	p.given = p.stripSuffixes(tokens)
+	return p
+}
+
+// stripSuffixes drops the suffixes at the end of tokens, keeping any
+// generational one in p.suffix.
+func (p *personName) stripSuffixes(tokens []string) []string {
+	for len(tokens) > 0 {
+		last := tokens[len(tokens)-1]
+		switch {
+		case strInSlice(last, generationalSuffixes):
+			p.suffix = last
+		case strInSlice(last, degreeSuffixes):
+		default:
+			return tokens
+		}
+		tokens = tokens[:len(tokens)-1]
+	}
+	return tokens
+}
+
+// nameTokens splits s on spaces, periods and hyphens, so "J.A." is two
+// initials, and drops any other punctuation.
+func nameTokens(s string) []string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '.' || r == '-' || r == ',':
+			return ' '
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == ' ':
+			return r
+		case r > 127:
+			// letters the folder doesn't know are kept as is
+			return r
+		}
+		return -1
+	}, s)
+	return strings.Fields(s)
+}
+
+// familySearchTerm is the longest part of name's family name as written, so
+// Drupal's case and accent insensitive search finds "Smith-Jones, Ann" from
+// "Smith Jones, Ann" and "Müller" from "Muller".
+func familySearchTerm(name string) string {
+	family, _, inverted := strings.Cut(name, ",")
+	parts := strings.FieldsFunc(family, func(r rune) bool {
+		return r == ' ' || r == '-' || r == '.'
+	})
+	if !inverted {
+		for len(parts) > 1 {
+			last := strings.ToLower(parts[len(parts)-1])
+			if !strInSlice(last, generationalSuffixes) && !strInSlice(last, degreeSuffixes) {
+				break
+			}
+			parts = parts[:len(parts)-1]
+		}
+		if len(parts) > 0 {
+			parts = parts[len(parts)-1:]
+		}
+	}
+	longest := ""
+	for _, part := range parts {
+		if len([]rune(part)) > len([]rune(longest)) {
+			longest = part
+		}
+	}
+	return longest
+}
+
+// scorePersonNames rates how likely a and b name the same person, from 0 to
+// 1. Family names must match exactly, given names in order, where an initial
+// matches any name it starts and a missing middle name counts for half.
+// Names that are the same once folded score 1. Different generational
+// suffixes, or only one name having one, take 0.1 off so they are at most
+// reported and never linked.
+func scorePersonNames(a, b personName) float64 {
+	if a.family == "" || a.family != b.family {
+		return 0
+	}
+	penalty := 0.0
+	if a.suffix != b.suffix {
+		penalty = 0.1
+	}
+	n := max(len(a.given), len(b.given))
+	if n == 0 {
+		return 1 - penalty
+	}
+	var total float64
+	for i := range n {
+		if i >= len(a.given) || i >= len(b.given) {
+			total += 0.5
+			continue
+		}
+		x, y := a.given[i], b.given[i]
+		switch {
+		case x == y:
+			total++
+		case len(x) == 1 && strings.HasPrefix(y, x), len(y) == 1 && strings.HasPrefix(x, y):
+			total += 0.75
+		default:
+			// John and Jane aren't the same person
+			return 0
+		}
+	}
+	return 0.5 + 0.5*total/float64(n) - penalty
+}
+
+// duplicateCandidates returns the person terms, existing or created earlier
+// in this transform, whose names score at least duplicateScore against name,
+// best first.
+func (d *drupalTermResolver) duplicateCandidates(name string) []possibleDuplicate {
+	if d.duplicateScore <= 0 {
+		return nil
+	}
+	parsed := parsePersonName(name)
+	if parsed.family == "" {
+		return nil
+	}
+
+	terms := d.newPeople
+	for _, t := range d.searchPeople(familySearchTerm(name)) {
+		known := false
+		for _, n := range d.newPeople {
+			known = known || n.tid == t.tid
+		}
+		if !known {
+			terms = append(terms, t)
+		}
+	}
+
+	var candidates []possibleDuplicate
+	for _, t := range terms {
+		score := scorePersonNames(parsed, parsePersonName(t.name))
+		if score < d.duplicateScore {
+			continue
+		}
+		candidates = append(candidates, possibleDuplicate{Candidate: d.termRef(t.tid), CandidateName: t.name, Score: score, tid: t.tid})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// reportDuplicates records candidates for the person term tid, which is 0
+// when the caller may not create it.
+func (d *drupalTermResolver) reportDuplicates(c contributor.Contributor, name string, tid int, candidates []possibleDuplicate) {
+	for _, p := range candidates {
+		p.Name, p.Email, p.Orcid = name, c.Email, c.Orcid
+		if tid != 0 {
+			p.Term = d.termRef(tid)
+		}
+		// lookup only transforms see a missing contributor on every row
+		seen := false
+		for _, other := range d.duplicates {
+			seen = seen || other == p
+		}
+		if !seen {
+			d.duplicates = append(d.duplicates, p)
+		}
+	}
+}
+
+// searchPeople returns the person terms whose names contain family, through
+// Drupal's JSON:API. Matching only advises catalogers, so a failed search is
+// logged and treated as finding nothing.
+func (d *drupalTermResolver) searchPeople(family string) []termCandidate {
+	if terms, ok := d.peopleSearches[family]; ok {
+		return terms
+	}
+	terms, err := d.fetchPeople(family)
+	if err != nil {
+		slog.Warn("Unable to search for similar person terms", "family", family, "err", err)
+	}
+	if d.peopleSearches == nil {
+		d.peopleSearches = map[string][]termCandidate{}
+	}
+	d.peopleSearches[family] = terms
+	return terms
+}
+
+// fetchPeople follows links.next so a common family name's later pages,
+// which may hold the same name, are compared too.
+func (d *drupalTermResolver) fetchPeople(family string) ([]termCandidate, error) {
+	params := url.Values{}
+	params.Set("filter[name][condition][path]", "name")
+	params.Set("filter[name][condition][operator]", "CONTAINS")
+	params.Set("filter[name][condition][value]", family)
+	params.Set("fields[taxonomy_term--person]", "name,drupal_internal__tid")
+	params.Set("page[limit]", "50")
+	next := fmt.Sprintf("%s/jsonapi/taxonomy_term/person?%s", d.baseURL, params.Encode())
+
+	var terms []termCandidate
+	for next != "" {
+		page, link, err := d.fetchPeoplePage(next)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, page...)
+		next = link
+	}
+	return terms, nil
+}
+
+func (d *drupalTermResolver) fetchPeoplePage(pageURL string) ([]termCandidate, string, error) {
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if d.password != "" {
+		req.SetBasicAuth(d.username, d.password)
+	}
+	req.Header.Set("Accept", "application/vnd.api+json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", fmt.Errorf("person search failed with status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data []struct {
+			Attributes struct {
+				Tid  int    `json:"drupal_internal__tid"`
+				Name string `json:"name"`
+			} `json:"attributes"`
+		} `json:"data"`
+		Links struct {
+			Next struct {
+				Href string `json:"href"`
+			} `json:"next"`
+		} `json:"links"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, "", err
+	}
+	terms := make([]termCandidate, 0, len(body.Data))
+	for _, t := range body.Data {
+		terms = append(terms, termCandidate{tid: t.Attributes.Tid, name: t.Attributes.Name})
+	}
+	return terms, body.Links.Next.Href, nil
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScorePersonNames(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{a: "Smith, John A.", b: "John A. Smith", want: 1},
+		{a: "Müller, Jürgen", b: "Muller, Jurgen", want: 1},
+		{a: "O'Brien, Pat", b: "OBrien, Pat", want: 1},
+		{a: "Smith, John, PhD", b: "Smith, John", want: 1},
+		{a: "John Smith Jr.", b: "Smith, John, Jr.", want: 1},
+		{a: "Smith, John, Jr.", b: "Smith, John, Sr.", want: 0.9},
+		{a: "Smith, John, Jr.", b: "Smith, John", want: 0.9},
+		{a: "John Smith Jr.", b: "John Smith", want: 0.9},
+		{a: "John Smith III", b: "John Smith II", want: 0.9},
+		{a: "Smith-Jones, Ann", b: "Smith Jones, Ann", want: 1},
+		{a: "Smith, John A.", b: "Smith, John", want: 0.875},
+		{a: "Smith, J.", b: "Smith, John", want: 0.875},
+		{a: "Smith, J.A.", b: "Smith, John Adam", want: 0.875},
+		{a: "Smith", b: "Smith, John", want: 0.75},
+		{a: "Smith, John", b: "Smith, Jane", want: 0},
+		{a: "Smith, John", b: "Smyth, John", want: 0},
+		{a: "", b: "", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"|"+tt.b, func(t *testing.T) {
+			if got := scorePersonNames(parsePersonName(tt.a), parsePersonName(tt.b)); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFamilySearchTerm(t *testing.T) {
+	tests := map[string]string{
+		"Smith, John A.":       "Smith",
+		"John A. Smith":        "Smith",
+		"John A. Smith Jr.":    "Smith",
+		"John Smith, Jr.":      "Smith",
+		"de la Cruz, Juana":    "Cruz",
+		"Smith-Jones, Ann":     "Smith",
+		"García Márquez, Gabo": "Márquez",
+	}
+	for name, want := range tests {
+		if got := familySearchTerm(name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestTransformCsvReportsPossibleDuplicates(t *testing.T) {
+	var creates int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			_, _ = w.Write([]byte(`[]`))
+		case "/jsonapi/taxonomy_term/person":
+			if got := r.URL.Query().Get("filter[name][condition][value]"); got != "Smith" {
+				t.Errorf("expected a search for Smith, got %q", got)
+			}
+			_, _ = w.Write([]byte(`{"data":[
+				{"attributes":{"drupal_internal__tid":7,"name":"Smith, John"}},
+				{"attributes":{"drupal_internal__tid":8,"name":"Smith, Jane"}},
+				{"attributes":{"drupal_internal__tid":9,"name":"Smith, John Adam"}}
+			]}`))
+		case "/taxonomy/term":
+			creates++
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"tid":[{"value":%d}]}`, 100+creates)))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+	// John Smith is Smith, John, which is still reported. Smith, J. A. could
+	// be any of the existing terms, so it is created and they are reported,
+	// as is the later Smith, J. who could be any of them or Smith, J. A.
+	csvContent := `Title,Contributor
+One,"{""name"":""relators:aut:person:John Smith""}"
+Two,"{""name"":""relators:aut:person:Smith, J. A."",""email"":""jas@lehigh.edu""}"
+Three,"{""name"":""relators:aut:person:Smith, J.""}"
+`
+	tests := []struct {
+		name       string
+		role       Role
+		statusCode int
+		target     []string
+		duplicates string
+	}{
+		{
+			name:       "ingester",
+			role:       RoleIngester,
+			statusCode: http.StatusOK,
+			target:     []string{"relators:aut:7", "relators:aut:101", "relators:aut:102"},
+			duplicates: "name,email,orcid,term,candidate,candidate_name,score\n" +
+				"John Smith,,,7,7,\"Smith, John\",1.00\n" +
+				"John Smith,,,7,9,\"Smith, John Adam\",0.88\n" +
+				"\"Smith, J. A.\",jas@lehigh.edu,,101,9,\"Smith, John Adam\",0.88\n" +
+				"\"Smith, J. A.\",jas@lehigh.edu,,101,7,\"Smith, John\",0.81\n" +
+				"\"Smith, J. A.\",jas@lehigh.edu,,101,8,\"Smith, Jane\",0.81\n" +
+				"\"Smith, J.\",,,102,101,\"Smith, J. A.\",0.88\n" +
+				"\"Smith, J.\",,,102,7,\"Smith, John\",0.88\n" +
+				"\"Smith, J.\",,,102,8,\"Smith, Jane\",0.88\n" +
+				"\"Smith, J.\",,,102,9,\"Smith, John Adam\",0.81\n",
+		},
+		{
+			name:       "dry run",
+			role:       RoleEditor,
+			statusCode: http.StatusOK,
+			target:     []string{"relators:aut:7", "relators:aut:pending-1", "relators:aut:pending-2"},
+			duplicates: "name,email,orcid,term,candidate,candidate_name,score\n" +
+				"John Smith,,,7,7,\"Smith, John\",1.00\n" +
+				"John Smith,,,7,9,\"Smith, John Adam\",0.88\n" +
+				"\"Smith, J. A.\",jas@lehigh.edu,,pending-1,9,\"Smith, John Adam\",0.88\n" +
+				"\"Smith, J. A.\",jas@lehigh.edu,,pending-1,7,\"Smith, John\",0.81\n" +
+				"\"Smith, J. A.\",jas@lehigh.edu,,pending-1,8,\"Smith, Jane\",0.81\n" +
+				"\"Smith, J.\",,,pending-2,pending-1,\"Smith, J. A.\",0.88\n" +
+				"\"Smith, J.\",,,pending-2,7,\"Smith, John\",0.88\n" +
+				"\"Smith, J.\",,,pending-2,8,\"Smith, Jane\",0.88\n" +
+				"\"Smith, J.\",,,pending-2,9,\"Smith, John Adam\",0.81\n",
+		},
+		{name: "editor", role: RoleEditor, statusCode: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creates = 0
+			// forget the terms the ingester created
+			sharedTermCache.flush()
+			url := "/"
+			if tt.name == "dry run" {
+				url = "/?dry_run=true"
+			}
+			req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(csvContent))
+			req.Header.Set("Content-Type", "text/csv")
+			req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: tt.role}))
+			rec := httptest.NewRecorder()
+
+			TransformCsv(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+			if tt.statusCode != http.StatusOK {
+				var got unknownTermsError
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("failed decoding response: %v", err)
+				}
+				if len(got.Terms) != 2 || len(got.PossibleDuplicates) != 8 || got.PossibleDuplicates[0].Term != "7" || got.PossibleDuplicates[2].Term != "" {
+					t.Fatalf("expected 2 unknown contributors with 8 possible duplicates, got %+v", got)
+				}
+				return
+			}
+			files := readZipFiles(t, rec.Body.Bytes())
+			for _, want := range tt.target {
+				if !strings.Contains(files["target.csv"], want) {
+					t.Errorf("expected %q in target.csv, got %s", want, files["target.csv"])
+				}
+			}
+			if got := files[possibleDuplicatesCSVName]; got != tt.duplicates {
+				t.Errorf("expected %s %q, got %q", possibleDuplicatesCSVName, tt.duplicates, got)
+			}
+		})
+	}
+}
+
+// A son is never linked to his father's term, only reported.
+func TestTransformCsvKeepsSuffixesApart(t *testing.T) {
+	var creates int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			_, _ = w.Write([]byte(`[]`))
+		case "/jsonapi/taxonomy_term/person":
+			_, _ = w.Write([]byte(`{"data":[{"attributes":{"drupal_internal__tid":7,"name":"Smith, John, Sr."}}]}`))
+		case "/taxonomy/term":
+			creates++
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"tid":[{"value":%d}]}`, 100+creates)))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+	sharedTermCache.flush()
+
+	csvContent := `Title,Contributor
+One,"{""name"":""relators:aut:person:Smith, John, Jr.""}"
+Two,"{""name"":""relators:aut:person:John Smith Sr.""}"
+`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(csvContent))
+	req.Header.Set("Content-Type", "text/csv")
+	req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: RoleIngester}))
+	rec := httptest.NewRecorder()
+
+	TransformCsv(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	files := readZipFiles(t, rec.Body.Bytes())
+	for _, want := range []string{"relators:aut:101", "relators:aut:7"} {
+		if !strings.Contains(files["target.csv"], want) {
+			t.Errorf("expected %q in target.csv, got %s", want, files["target.csv"])
+		}
+	}
+	want := "name,email,orcid,term,candidate,candidate_name,score\n" +
+		"\"Smith, John, Jr.\",,,101,7,\"Smith, John, Sr.\",0.90\n" +
+		"John Smith Sr.,,,7,7,\"Smith, John, Sr.\",1.00\n" +
+		"John Smith Sr.,,,7,101,\"Smith, John, Jr.\",0.90\n"
+	if got := files[possibleDuplicatesCSVName]; got != want {
+		t.Errorf("expected %s %q, got %q", possibleDuplicatesCSVName, want, got)
+	}
+}
+
+// A second term with the same name on a later page of the search means the
+// name can't be linked to either.
+func TestTransformCsvSearchesEveryPeoplePage(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			_, _ = w.Write([]byte(`[]`))
+		case "/jsonapi/taxonomy_term/person":
+			if r.URL.Query().Get("page[offset]") == "50" {
+				_, _ = w.Write([]byte(`{"data":[{"attributes":{"drupal_internal__tid":10,"name":"John Smith"}}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"data":[{"attributes":{"drupal_internal__tid":7,"name":"Smith, John"}}],
+				"links":{"next":{"href":"%s/jsonapi/taxonomy_term/person?page%%5Boffset%%5D=50"}}}`, ts.URL)))
+		case "/taxonomy/term":
+			_, _ = w.Write([]byte(`{"tid":[{"value":101}]}`))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+	sharedTermCache.flush()
+
+	csvContent := `Title,Contributor
+One,"{""name"":""relators:aut:person:John Smith""}"
+`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(csvContent))
+	req.Header.Set("Content-Type", "text/csv")
+	req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: RoleIngester}))
+	rec := httptest.NewRecorder()
+
+	TransformCsv(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	files := readZipFiles(t, rec.Body.Bytes())
+	if !strings.Contains(files["target.csv"], "relators:aut:101") {
+		t.Errorf("expected a new term in target.csv, got %s", files["target.csv"])
+	}
+	want := "name,email,orcid,term,candidate,candidate_name,score\n" +
+		"John Smith,,,101,7,\"Smith, John\",1.00\n" +
+		"John Smith,,,101,10,John Smith,1.00\n"
+	if got := files[possibleDuplicatesCSVName]; got != want {
+		t.Errorf("expected %s %q, got %q", possibleDuplicatesCSVName, want, got)
+	}
+}
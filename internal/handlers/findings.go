@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lehigh-university-libraries/fabricator/internal/tgn"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// legacyCheckMediaType can be sent in the Accept header (or format=legacy in the
+// query string) to get the original flat {"A2": "message"} check response that
+// older sheet scripts expect.
+const legacyCheckMediaType = "application/vnd.fabricator.check.legacy+json"
+
+// checkFinding is a single problem CheckMyWork found in the sheet.
+type checkFinding struct {
+	Cell   string `json:"cell"`
+	Column string `json:"column,omitempty"`
+	// Value and Position (1-based) identify which " ; " separated value
+	// failed when the cell holds more than one.
+	Value    string `json:"value,omitempty"`
+	Position int    `json:"position,omitempty"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"`
+	// Candidates are replacement values the sheet can offer in one click.
+	Candidates []tgn.Candidate `json:"candidates,omitempty"`
+
+	row int
+	col int
+}
+
+// checkResponse is the structured /workbench/check response body.
+type checkResponse struct {
+	Findings []checkFinding `json:"findings"`
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+}
+
+// checkReport collects findings keyed by cell.
+type checkReport struct {
+	cells    map[string][]*checkFinding
+	findings []*checkFinding
+}
+
+func newCheckReport() *checkReport {
+	return &checkReport{cells: map[string][]*checkFinding{}}
+}
+
+// add records a finding for row (zero based, excluding the header) and col.
+func (r *checkReport) add(row, col int, column string, rule checkRule, message string) {
+	r.addValue(row, col, column, rule, message, "", 0)
+}
+
+// addValue records a finding against the value at position (1-based) of a
+// multi-value cell. Repeats of the same problem with the same value are dropped
+// and return nil.
+func (r *checkReport) addValue(row, col int, column string, rule checkRule, message, value string, position int) *checkFinding {
+	f := &checkFinding{
+		Cell:     cellName(row, col),
+		Column:   column,
+		Value:    value,
+		Position: position,
+		Severity: rule.severity(),
+		Rule:     rule.ID,
+		Message:  message,
+		Fix:      rule.Fix,
+		row:      row,
+		col:      col,
+	}
+	for _, existing := range r.cells[f.Cell] {
+		if existing.Rule == f.Rule && existing.Message == f.Message && existing.Value == f.Value {
+			return nil
+		}
+	}
+	r.cells[f.Cell] = append(r.cells[f.Cell], f)
+	r.findings = append(r.findings, f)
+	return f
+}
+
+// sorted orders findings by row then column, keeping the order they were
+// found in within a cell.
+func (r *checkReport) sorted() []checkFinding {
+	findings := make([]checkFinding, len(r.findings))
+	for i, f := range r.findings {
+		findings[i] = *f
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].row != findings[j].row {
+			return findings[i].row < findings[j].row
+		}
+		return findings[i].col < findings[j].col
+	})
+	return findings
+}
+
+func (r *checkReport) response() checkResponse {
+	resp := checkResponse{Findings: r.sorted()}
+	for _, f := range resp.Findings {
+		switch f.Severity {
+		case severityError:
+			resp.Errors++
+		case severityWarning:
+			resp.Warnings++
+		}
+	}
+	return resp
+}
+
+// legacy flattens the report into the original cell -> message map, joining
+// multiple errors in a cell with "; ". Only errors are included since older
+// consumers treat every entry as blocking.
+func (r *checkReport) legacy() map[string]string {
+	errors := map[string]string{}
+	for cell, findings := range r.cells {
+		var msgs []string
+		for _, f := range findings {
+			if f.Severity != severityError {
+				continue
+			}
+			msg := f.Message
+			if f.Value != "" {
+				msg = fmt.Sprintf("%s (%s)", msg, f.Value)
+			}
+			msgs = append(msgs, msg)
+		}
+		if len(msgs) > 0 {
+			errors[cell] = strings.Join(msgs, "; ")
+		}
+	}
+	return errors
+}
+
+func wantsLegacyCheckResponse(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "legacy" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), legacyCheckMediaType)
+}
+
+// cellName converts a zero based data row and column into the sheet's A1
+// notation. A negative row addresses the whole column.
+func cellName(row, col int) string {
+	c := numberToExcelColumn(col)
+	if row < 0 {
+		return c
+	}
+	return c + strconv.Itoa(row+2)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCheckReportCollectsEveryFindingInACell(t *testing.T) {
+	integer := checkRule{ID: "ppi-integer"}
+	warn := checkRule{ID: "ppi-range", Severity: severityWarning, Fix: "Use 300 or more"}
+
+	report := newCheckReport()
+	report.addValue(0, 1, "PPI", integer, "Must be an integer", "x", 2)
+	report.addValue(0, 1, "PPI", integer, "Must be an integer", "y", 3)
+	// the same problem with the same value is only reported once
+	report.addValue(0, 1, "PPI", integer, "Must be an integer", "y", 3)
+	report.add(0, 1, "PPI", warn, "Low resolution")
+	report.add(0, 0, "Title", checkRule{ID: "title-required"}, "Missing value")
+
+	got, err := json.Marshal(report.response())
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+	expected := `{"findings":[` +
+		`{"cell":"A2","column":"Title","severity":"error","rule":"title-required","message":"Missing value"},` +
+		`{"cell":"B2","column":"PPI","value":"x","position":2,"severity":"error","rule":"ppi-integer","message":"Must be an integer"},` +
+		`{"cell":"B2","column":"PPI","value":"y","position":3,"severity":"error","rule":"ppi-integer","message":"Must be an integer"},` +
+		`{"cell":"B2","column":"PPI","severity":"warning","rule":"ppi-range","message":"Low resolution","fix":"Use 300 or more"}` +
+		`],"errors":3,"warnings":1}`
+	if string(got) != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	legacy := report.legacy()
+	if legacy["B2"] != "Must be an integer (x); Must be an integer (y)" {
+		t.Fatalf("unexpected legacy message %q", legacy["B2"])
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pageParentModels are the object models a Page row can hang under.
+var pageParentModels = []string{"Paged Content", "Compound Object"}
+
+// structural rules reported by the whole-sheet hierarchy pass.
+var (
+	parentCycleRule = checkRule{
+		ID:  "parent-cycle",
+		Fix: "Point one of these rows at a parent outside the loop",
+	}
+	pageParentModelRule = checkRule{
+		ID:  "page-parent-model",
+		Fix: "Set the parent's Object Model to Paged Content or Compound Object",
+	}
+	parentIsPageRule = checkRule{
+		ID:  "parent-is-page",
+		Fix: "Use the Upload ID of the Paged Content or Compound Object row instead",
+	}
+	childSortOrderRule = checkRule{
+		ID:  "child-sort-order",
+		Fix: "Number the children of each parent 1, 2, 3, ... with no gaps or repeats",
+	}
+)
+
+// hierarchyNode is one sheet row as seen by the hierarchy pass.
+type hierarchyNode struct {
+	row       int
+	uploadID  string
+	parentID  string
+	model     string
+	sortOrder string
+}
+
+// sheetUploadIDs returns every Upload ID in rows so a Page/Item Parent ID can
+// reference a row that appears later in the sheet.
+func sheetUploadIDs(header []string, rows [][]string) map[string]bool {
+	ids := map[string]bool{}
+	for _, row := range rows {
+		if id := strings.TrimSpace(ColumnValue("Upload ID", header, row)); id != "" {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// checkHierarchy validates the parent/child graph formed by the Upload ID and
+// Page/Item Parent ID columns across the whole sheet. Unknown parents and rows
+// that are their own parent are left to the parent-id rule.
+func checkHierarchy(header []string, rows [][]string, report *checkReport) {
+	parentCol := IndexOf("Page/Item Parent ID", header)
+	if parentCol == -1 {
+		return
+	}
+	sortCol := IndexOf("Child Sort Order", header)
+
+	nodes := make([]*hierarchyNode, 0, len(rows))
+	byID := map[string]*hierarchyNode{}
+	for i, row := range rows {
+		n := &hierarchyNode{
+			row:       i,
+			uploadID:  strings.TrimSpace(ColumnValue("Upload ID", header, row)),
+			parentID:  strings.TrimSpace(ColumnValue("Page/Item Parent ID", header, row)),
+			model:     strings.TrimSpace(ColumnValue("Object Model", header, row)),
+			sortOrder: strings.TrimSpace(ColumnValue("Child Sort Order", header, row)),
+		}
+		nodes = append(nodes, n)
+		// duplicates are reported by the upload-id-unique rule, the first one wins here
+		if _, ok := byID[n.uploadID]; n.uploadID != "" && !ok {
+			byID[n.uploadID] = n
+		}
+	}
+
+	for _, n := range nodes {
+		if n.parentID == "" || n.parentID == n.uploadID {
+			continue
+		}
+		if cycle := parentCycle(n, byID); cycle != nil {
+			report.add(n.row, parentCol, header[parentCol], parentCycleRule,
+				fmt.Sprintf("Parent IDs form a cycle: %s", strings.Join(cycle, " -> ")))
+			continue
+		}
+
+		parent, ok := byID[n.parentID]
+		if !ok {
+			continue
+		}
+		if parent.model == "Page" {
+			report.add(n.row, parentCol, header[parentCol], parentIsPageRule,
+				fmt.Sprintf("Parent ID %s is a Page, pages can not have children", n.parentID))
+			continue
+		}
+		if n.model == "Page" && !strInSlice(parent.model, pageParentModels) {
+			report.add(n.row, parentCol, header[parentCol], pageParentModelRule,
+				fmt.Sprintf("Pages must belong to %s, parent ID %s is %q", strings.Join(pageParentModels, " or "), n.parentID, parent.model))
+		}
+	}
+
+	if sortCol != -1 {
+		checkChildSortOrder(nodes, header[sortCol], sortCol, report)
+	}
+}
+
+// parentCycle follows n's parents and returns the upload IDs along the way
+// when they lead back to n, or nil when they end somewhere else.
+func parentCycle(n *hierarchyNode, byID map[string]*hierarchyNode) []string {
+	path := []string{n.uploadID}
+	visited := map[*hierarchyNode]bool{n: true}
+	for cur := n; cur.parentID != ""; {
+		next, ok := byID[cur.parentID]
+		if !ok {
+			return nil
+		}
+		path = append(path, next.uploadID)
+		if next == n {
+			return path
+		}
+		// a loop that doesn't include n is reported from one of its own rows
+		if visited[next] {
+			return nil
+		}
+		visited[next] = true
+		cur = next
+	}
+	return nil
+}
+
+// checkChildSortOrder makes sure the children of each parent are numbered
+// uniquely and without gaps. Parents whose children have no sort order at all
+// are skipped since Workbench falls back to sheet order.
+func checkChildSortOrder(nodes []*hierarchyNode, column string, col int, report *checkReport) {
+	var parents []string
+	children := map[string][]*hierarchyNode{}
+	for _, n := range nodes {
+		if n.parentID == "" {
+			continue
+		}
+		if _, ok := children[n.parentID]; !ok {
+			parents = append(parents, n.parentID)
+		}
+		children[n.parentID] = append(children[n.parentID], n)
+	}
+
+	for _, parentID := range parents {
+		siblings := children[parentID]
+		ordered := false
+		for _, n := range siblings {
+			if n.sortOrder != "" {
+				ordered = true
+				break
+			}
+		}
+		if !ordered {
+			continue
+		}
+
+		first := map[int]*hierarchyNode{}
+		for _, n := range siblings {
+			if n.sortOrder == "" {
+				report.add(n.row, col, column, childSortOrderRule,
+					fmt.Sprintf("Missing Child Sort Order, other children of parent ID %s have one", parentID))
+				continue
+			}
+			order, err := strconv.Atoi(n.sortOrder)
+			if err != nil {
+				report.add(n.row, col, column, childSortOrderRule, "Child Sort Order must be an integer")
+				continue
+			}
+			if _, ok := first[order]; ok {
+				report.add(n.row, col, column, childSortOrderRule,
+					fmt.Sprintf("Duplicate Child Sort Order %d for parent ID %s", order, parentID))
+				continue
+			}
+			first[order] = n
+		}
+
+		orders := make([]int, 0, len(first))
+		for order := range first {
+			orders = append(orders, order)
+		}
+		if len(orders) == 0 {
+			continue
+		}
+		sort.Ints(orders)
+		// numbering may start at 0 or 1
+		previous := 0
+		if orders[0] == 0 {
+			previous = -1
+		}
+		for _, order := range orders {
+			if order > previous+1 {
+				report.add(first[order].row, col, column, childSortOrderRule,
+					fmt.Sprintf("Child Sort Order for parent ID %s skips %s", parentID, numberRange(previous+1, order-1)))
+			}
+			previous = order
+		}
+	}
+}
+
+func numberRange(from, to int) string {
+	if from == to {
+		return strconv.Itoa(from)
+	}
+	return fmt.Sprintf("%d-%d", from, to)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckHierarchy(t *testing.T) {
+	header := []string{"Upload ID", "Object Model", "Page/Item Parent ID", "Child Sort Order"}
+	tests := []struct {
+		name     string
+		rows     [][]string
+		expected map[string]string
+	}{
+		{
+			name: "book with pages defined before the book",
+			rows: [][]string{
+				{"p1", "Page", "book", "1"},
+				{"p2", "Page", "book", "2"},
+				{"book", "Paged Content", "", ""},
+			},
+			expected: map[string]string{},
+		},
+		{
+			name: "cycle",
+			rows: [][]string{
+				{"a", "Compound Object", "b", ""},
+				{"b", "Compound Object", "c", ""},
+				{"c", "Compound Object", "a", ""},
+				{"d", "Image", "a", ""},
+			},
+			expected: map[string]string{
+				"C2": "Parent IDs form a cycle: a -> b -> c -> a",
+				"C3": "Parent IDs form a cycle: b -> c -> a -> b",
+				"C4": "Parent IDs form a cycle: c -> a -> b -> c",
+			},
+		},
+		{
+			name: "page under an image",
+			rows: [][]string{
+				{"img", "Image", "", ""},
+				{"p1", "Page", "img", "1"},
+			},
+			expected: map[string]string{
+				"C3": `Pages must belong to Paged Content or Compound Object, parent ID img is "Image"`,
+			},
+		},
+		{
+			name: "child of a page",
+			rows: [][]string{
+				{"book", "Paged Content", "", ""},
+				{"p1", "Page", "book", "1"},
+				{"p2", "Page", "p1", "1"},
+			},
+			expected: map[string]string{
+				"C4": "Parent ID p1 is a Page, pages can not have children",
+			},
+		},
+		{
+			name: "sort order duplicates, gaps and blanks",
+			rows: [][]string{
+				{"book", "Paged Content", "", ""},
+				{"p1", "Page", "book", "1"},
+				{"p2", "Page", "book", "1"},
+				{"p3", "Page", "book", "4"},
+				{"p4", "Page", "book", ""},
+				{"p5", "Page", "book", "two"},
+			},
+			expected: map[string]string{
+				"D4": "Duplicate Child Sort Order 1 for parent ID book",
+				"D5": "Child Sort Order for parent ID book skips 2-3",
+				"D6": "Missing Child Sort Order, other children of parent ID book have one",
+				"D7": "Child Sort Order must be an integer",
+			},
+		},
+		{
+			name: "sort order can start at zero but not skip the first page",
+			rows: [][]string{
+				{"a", "Paged Content", "", ""},
+				{"a0", "Page", "a", "0"},
+				{"a1", "Page", "a", "1"},
+				{"b", "Paged Content", "", ""},
+				{"b2", "Page", "b", "2"},
+			},
+			expected: map[string]string{
+				"D6": "Child Sort Order for parent ID b skips 1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := newCheckReport()
+			checkHierarchy(header, tt.rows, report)
+			if got := report.legacy(); !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	jwt "github.com/lestrrat-go/jwx/v3/jwt"
+)
+
+// auth.json is the default policy for which Google identities may call the
+// service. It can be replaced at startup with FABRICATOR_AUTH_CONFIG.
+//
+//go:embed auth.json
+var defaultAuthPolicy []byte
+
+// authPolicy decides which verified Google ID tokens are accepted.
+type authPolicy struct {
+	// Domains lists the email domains that may sign in.
+	Domains []string `json:"domains"`
+	// Audiences lists the OAuth client IDs a token may be minted for, e.g.
+	// the Apps Script project's. Empty rejects every Google ID token, since
+	// any Google app's tokens would otherwise be accepted.
+	Audiences []string `json:"audiences,omitempty"`
+	// Issuers lists the accepted token issuers.
+	Issuers []string `json:"issuers"`
+	// Allow, when set, limits sign in to these emails (which must still be
+	// in an allowed domain).
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists emails that may never sign in.
+	Deny []string `json:"deny,omitempty"`
+	// Roles grants emails a role other than DefaultRole.
+	Roles map[string]Role `json:"roles,omitempty"`
+	// DefaultRole is the role of everyone else who may sign in.
+	DefaultRole Role `json:"default_role"`
+}
+
+var activeAuthPolicy = mustParseAuthPolicy(defaultAuthPolicy)
+
+// LoadAuthPolicy replaces the built-in auth policy with the JSON file at path.
+// An empty path keeps the defaults. It should be called once at startup.
+func LoadAuthPolicy(path string) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read auth policy %s: %w", path, err)
+	}
+	policy, err := parseAuthPolicy(raw)
+	if err != nil {
+		return fmt.Errorf("invalid auth policy %s: %w", path, err)
+	}
+	activeAuthPolicy = policy
+
+	return nil
+}
+
+func mustParseAuthPolicy(raw []byte) authPolicy {
+	policy, err := parseAuthPolicy(raw)
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+func parseAuthPolicy(raw []byte) (authPolicy, error) {
+	var policy authPolicy
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return authPolicy{}, err
+	}
+	if len(policy.Domains) == 0 {
+		return authPolicy{}, fmt.Errorf("domains is required")
+	}
+	if len(policy.Issuers) == 0 {
+		return authPolicy{}, fmt.Errorf("issuers is required")
+	}
+	for i, d := range policy.Domains {
+		policy.Domains[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+	}
+	for _, list := range [][]string{policy.Allow, policy.Deny} {
+		for i, email := range list {
+			list[i] = strings.ToLower(strings.TrimSpace(email))
+		}
+	}
+	if policy.DefaultRole == "" {
+		policy.DefaultRole = RoleEditor
+	}
+	role, err := parseRole(string(policy.DefaultRole))
+	if err != nil {
+		return authPolicy{}, fmt.Errorf("default_role: %w", err)
+	}
+	policy.DefaultRole = role
+	roles := make(map[string]Role, len(policy.Roles))
+	for email, r := range policy.Roles {
+		role, err := parseRole(string(r))
+		if err != nil {
+			return authPolicy{}, fmt.Errorf("roles %s: %w", email, err)
+		}
+		roles[strings.ToLower(strings.TrimSpace(email))] = role
+	}
+	policy.Roles = roles
+
+	return policy, nil
+}
+
+// WarnIfGoogleDisabled logs when the active policy has no audiences, so only
+// API keys are accepted.
+func WarnIfGoogleDisabled() {
+	if len(activeAuthPolicy.Audiences) == 0 {
+		slog.Error("auth policy has no audiences, Google ID tokens are rejected until the Apps Script client ID is added")
+	}
+}
+
+// checkToken returns an error when token was not minted for us by an accepted
+// issuer. The token's signature and expiry are already verified.
+func (p authPolicy) checkToken(token jwt.Token) error {
+	issuer, _ := token.Issuer()
+	if !strInSlice(issuer, p.Issuers) {
+		return fmt.Errorf("issuer %q is not allowed", issuer)
+	}
+	if len(p.Audiences) == 0 {
+		return fmt.Errorf("no audiences are configured")
+	}
+	audiences, _ := token.Audience()
+	for _, aud := range audiences {
+		if strInSlice(aud, p.Audiences) {
+			return nil
+		}
+	}
+	return fmt.Errorf("audience %v is not allowed", audiences)
+}
+
+// allowsEmail reports whether a verified email may sign in.
+func (p authPolicy) allowsEmail(email string) bool {
+	email = strings.ToLower(email)
+	at := strings.LastIndex(email, "@")
+	if at < 1 || !strInSlice(email[at+1:], p.Domains) {
+		return false
+	}
+	if strInSlice(email, p.Deny) {
+		return false
+	}
+	return len(p.Allow) == 0 || strInSlice(email, p.Allow)
+}
+
+// roleFor returns the role of an allowed email.
+func (p authPolicy) roleFor(email string) Role {
+	if role, ok := p.Roles[strings.ToLower(email)]; ok {
+		return role
+	}
+	return p.DefaultRole
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/lehigh-university-libraries/fabricator/internal/contributor"
+)
+
+// DefaultLookupWorkers is how many contributor lookups a transform runs at
+// once by default.
+const DefaultLookupWorkers = 8
+
+var lookupWorkers = DefaultLookupWorkers
+
+// ConfigureLookupWorkers sets how many contributor lookups a transform runs
+// at once. 1 looks them up one at a time. It should be called once at startup.
+func ConfigureLookupWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	lookupWorkers = n
+}
+
+// sheetContributors returns each distinct contributor in the sheet's
+// contributor columns, in the order they first appear. Values that aren't
+// valid JSON are skipped here and reported by the row by row pass.
+func sheetContributors(headers []string, records [][]string, mappings columnMappings) []contributor.Contributor {
+	var columns []int
+	for i, header := range headers {
+		if mapping, ok := mappings[strings.TrimSpace(header)]; ok && mapping.Kind == columnKindContributor {
+			columns = append(columns, i)
+		}
+	}
+
+	seen := map[contributor.Contributor]bool{}
+	var contributors []contributor.Contributor
+	for _, record := range records {
+		for _, i := range columns {
+			if i >= len(record) || record[i] == "" {
+				continue
+			}
+			for _, str := range strings.Split(record[i], " ; ") {
+				var c contributor.Contributor
+				if err := json.Unmarshal([]byte(str), &c); err != nil || seen[c] {
+					continue
+				}
+				seen[c] = true
+				contributors = append(contributors, c)
+			}
+		}
+	}
+	return contributors
+}
+
+// prefetch looks up the terms for every contributor in parallel before the
+// sheet is resolved row by row. Only lookups run here. Terms are still created
+// by the row by row pass, so they are created in row order, no two workers can
+// create the same term, and dry run placeholders and the transform's output
+// match a serial run exactly. Lookups that found nothing are forgotten once
+// that pass creates a term they would find, so a later row finds it.
+// Lookup errors are left for that pass to report.
+func (d *drupalTermResolver) prefetch(contributors []contributor.Contributor) {
+	var first []url.Values
+	// people known only by their institution need its tid to be looked up
+	var byInstitution []contributor.Contributor
+	for _, c := range contributors {
+		name, err := c.ParseName()
+		if err != nil {
+			continue
+		}
+		if name.Vocab == "person" {
+			if c, err = normalizeContributor(c); err != nil {
+				continue
+			}
+		}
+		switch {
+		case name.Vocab == "corporate_body":
+			first = append(first, institutionLookupParams(name.Display))
+		case name.Vocab != "person":
+		case c.Email != "" || c.Orcid != "":
+			first = append(first, personLookupParams(c, name.Display, 0))
+		case c.Institution != "":
+			first = append(first, institutionLookups(c.Institution, c.InstitutionRor)...)
+			byInstitution = append(byInstitution, c)
+		default:
+			first = append(first, personLookupParams(c, name.Display, 0))
+		}
+	}
+	d.lookupAll(first)
+
+	var second []url.Values
+	for _, c := range byInstitution {
+		// the first lookup that found the institution is the one
+		// ensureInstitution will use
+		for _, params := range institutionLookups(c.Institution, c.InstitutionRor) {
+			entry, ok := d.recall(termCacheKey(d.baseURL, params))
+			if !ok || !entry.found {
+				continue
+			}
+			name, _ := c.ParseName()
+			second = append(second, personLookupParams(c, name.Display, entry.tid))
+			break
+		}
+	}
+	d.lookupAll(second)
+}
+
+// lookupAll runs each distinct lookup once across lookupWorkers goroutines.
+func (d *drupalTermResolver) lookupAll(lookups []url.Values) {
+	jobs := make(chan url.Values)
+	var wg sync.WaitGroup
+	for range min(lookupWorkers, len(lookups)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for params := range jobs {
+				if _, _, _, err := d.lookupTerm(params); err != nil {
+					slog.Warn("Unable to prefetch term", "params", params.Encode(), "err", err)
+				}
+			}
+		}()
+	}
+
+	queued := map[string]bool{}
+	for _, params := range lookups {
+		key := params.Encode()
+		if queued[key] {
+			continue
+		}
+		queued[key] = true
+		jobs <- params
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// recall returns a lookup this transform already made.
+func (d *drupalTermResolver) recall(key string) (termCacheEntry, bool) {
+	d.lookupsMu.Lock()
+	defer d.lookupsMu.Unlock()
+	entry, ok := d.lookups[key]
+	return entry, ok
+}
+
+func (d *drupalTermResolver) memorize(entry termCacheEntry) {
+	d.lookupsMu.Lock()
+	defer d.lookupsMu.Unlock()
+	if d.lookups == nil {
+		d.lookups = map[string]termCacheEntry{}
+	}
+	d.lookups[entry.key] = entry
+}
+
+// forgetLookups drops this transform's lookups that found tid.
+func (d *drupalTermResolver) forgetLookups(tid int) {
+	d.lookupsMu.Lock()
+	defer d.lookupsMu.Unlock()
+	for key, entry := range d.lookups {
+		if entry.found && entry.tid == tid {
+			delete(d.lookups, key)
+		}
+	}
+}
+
+// forgetMisses drops this transform's lookups among lookups that found
+// nothing.
+func (d *drupalTermResolver) forgetMisses(lookups []url.Values) {
+	d.lookupsMu.Lock()
+	defer d.lookupsMu.Unlock()
+	for _, params := range lookups {
+		key := termCacheKey(d.baseURL, params)
+		if entry, ok := d.lookups[key]; ok && !entry.found {
+			delete(d.lookups, key)
+		}
+	}
+}
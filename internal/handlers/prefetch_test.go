@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransformCsvPrefetchMatchesSerial(t *testing.T) {
+	originalWorkers, originalCache := lookupWorkers, sharedTermCache
+	defer func() {
+		lookupWorkers, sharedTermCache = originalWorkers, originalCache
+	}()
+	// each run starts cold so the parallel one can't reuse the serial one's lookups
+	ConfigureTermCache(0, 0, 0)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+	var sheet strings.Builder
+	sheet.WriteString("Title,Contributor\n")
+	for i := range 60 {
+		contributors := []string{
+			fmt.Sprintf(`{""name"":""relators:aut:person:Author %d"",""email"":""author%d@lehigh.edu""}`, i%15, i%15),
+			fmt.Sprintf(`{""name"":""relators:ths:person:Advisor %d"",""institution"":""College %d""}`, i%7, i%7%3),
+			fmt.Sprintf(`{""name"":""relators:pbl:corporate_body:Press %d""}`, i%4),
+		}
+		// editors first given with an email are created, then found by their
+		// institution in later rows, as they are when every row is looked up
+		// live
+		if i < 5 {
+			contributors = append(contributors, fmt.Sprintf(`{""name"":""relators:edt:person:Editor %d"",""email"":""editor%d@lehigh.edu"",""institution"":""College 0""}`, i, i))
+		} else {
+			contributors = append(contributors, fmt.Sprintf(`{""name"":""relators:edt:person:Editor %d"",""institution"":""College 0""}`, i%5))
+		}
+		fmt.Fprintf(&sheet, "Item %d,\"%s\"\n", i, strings.Join(contributors, " ; "))
+	}
+
+	existing := map[string]bool{"Author 0": true, "Author 2": true, "Author 4": true, "College 0": true}
+	run := func(workers int, query string) (map[string]string, int32, map[string]int) {
+		t.Helper()
+		ConfigureLookupWorkers(workers)
+		var (
+			mu       sync.Mutex
+			created  = map[string]int{}
+			tid      int
+			inFlight atomic.Int32
+			maxSeen  atomic.Int32
+		)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxSeen.Load()
+				if n <= m || maxSeen.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			switch r.URL.Path {
+			case "/term_from_term_name":
+				time.Sleep(time.Millisecond)
+				q := r.URL.Query()
+				name := q.Get("name")
+				mu.Lock()
+				id, ok := created[name]
+				mu.Unlock()
+				switch {
+				case ok:
+					_, _ = fmt.Fprintf(w, `[{"tid":[{"value":%d}],"name":[{"value":%q}]}]`, id, name)
+				case existing[name]:
+					_, _ = fmt.Fprintf(w, `[{"tid":[{"value":%d}],"name":[{"value":%q}]}]`, 1000+len(name), name)
+				default:
+					_, _ = w.Write([]byte(`[]`))
+				}
+			case "/taxonomy/term":
+				var body struct {
+					Name []struct {
+						Value string `json:"value"`
+					} `json:"name"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				mu.Lock()
+				tid++
+				name := body.Name[0].Value
+				if _, dup := created[name]; dup {
+					t.Errorf("created %s twice", name)
+				}
+				created[name] = tid
+				id := tid
+				mu.Unlock()
+				_, _ = fmt.Fprintf(w, `{"tid":[{"value":%d}]}`, id)
+			case "/jsonapi/taxonomy_term/person":
+				_, _ = w.Write([]byte(`{"data":[]}`))
+			default:
+				t.Fatalf("unexpected path: %s", r.URL.Path)
+			}
+		}))
+		defer ts.Close()
+		t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+
+		req := httptest.NewRequest(http.MethodPost, "/"+query, strings.NewReader(sheet.String()))
+		req.Header.Set("Content-Type", "text/csv")
+		req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: RoleIngester}))
+		rec := httptest.NewRecorder()
+		TransformCsv(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		files := readZipFiles(t, rec.Body.Bytes())
+		// created terms are stamped with the time they were created
+		if created, ok := files[termsCreatedCSVName]; ok {
+			var stripped []string
+			for _, line := range strings.Split(created, "\n") {
+				if _, rest, ok := strings.Cut(line, ","); ok {
+					stripped = append(stripped, rest)
+				}
+			}
+			files[termsCreatedCSVName] = strings.Join(stripped, "\n")
+		}
+		return files, maxSeen.Load(), created
+	}
+
+	for _, query := range []string{"", "?dry_run=true", "?terms=workbench"} {
+		t.Run("query "+query, func(t *testing.T) {
+			serial, serialMax, _ := run(1, query)
+			parallel, parallelMax, created := run(8, query)
+			if serialMax != 1 {
+				t.Errorf("expected one lookup at a time with 1 worker, saw %d", serialMax)
+			}
+			if parallelMax < 2 {
+				t.Errorf("expected lookups to run in parallel, saw at most %d", parallelMax)
+			}
+			if len(serial) != len(parallel) {
+				t.Fatalf("expected the same files, got %d and %d", len(serial), len(parallel))
+			}
+			for name, body := range serial {
+				if sortedColumns(t, parallel[name]) != sortedColumns(t, body) {
+					t.Errorf("%s differs:\nserial:\n%s\nparallel:\n%s", name, body, parallel[name])
+				}
+			}
+			if query == "" && len(created) == 0 {
+				t.Error("expected terms to be created")
+			}
+		})
+	}
+}
+
+// sortedColumns rewrites a CSV with its columns in name order, since target
+// CSV columns come out in map order.
+func sortedColumns(t *testing.T, body string) string {
+	t.Helper()
+	rows, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("failed reading CSV: %v", err)
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	order := make([]int, len(rows[0]))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return rows[0][order[a]] < rows[0][order[b]] })
+	var out strings.Builder
+	for _, row := range rows {
+		for _, i := range order {
+			out.WriteString(row[i] + "\t")
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// Creating a term only forgets the prefetched misses that could find it.
+func TestTransformCsvCreateKeepsUnrelatedMisses(t *testing.T) {
+	var creates atomic.Int32
+	var mu sync.Mutex
+	lookups := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			mu.Lock()
+			lookups[r.URL.Query().Get("name")]++
+			mu.Unlock()
+			_, _ = w.Write([]byte(`[]`))
+		case "/taxonomy/term":
+			_, _ = fmt.Fprintf(w, `{"tid":[{"value":%d}]}`, 100+creates.Add(1))
+		case "/jsonapi/taxonomy_term/person":
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+	sharedTermCache.flush()
+
+	csvContent := `Title,Contributor
+One,"{""name"":""relators:aut:person:New, Nia""}"
+Two,"{""name"":""relators:aut:person:Other, Oz""}"
+`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(csvContent))
+	req.Header.Set("Content-Type", "text/csv")
+	req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: RoleIngester}))
+	rec := httptest.NewRecorder()
+
+	TransformCsv(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if creates.Load() != 2 {
+		t.Fatalf("expected 2 creates, got %d", creates.Load())
+	}
+	for name, n := range lookups {
+		if n != 1 {
+			t.Errorf("expected %s to be looked up once, got %d", name, n)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Role is what a caller may do. Each role can do everything the roles before
+// it can.
+type Role string
+
+const (
+	// RoleViewer may check sheets and search TGN.
+	RoleViewer Role = "viewer"
+	// RoleEditor may also transform sheets, linking contributors that already
+	// have taxonomy terms.
+	RoleEditor Role = "editor"
+	// RoleIngester may also create the taxonomy terms a transform needs.
+	RoleIngester Role = "ingester"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer:   1,
+	RoleEditor:   2,
+	RoleIngester: 3,
+}
+
+func parseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return role, nil
+}
+
+// Has reports whether id's role includes role.
+func (id Identity) Has(role Role) bool {
+	rank, ok := roleRanks[role]
+	return ok && roleRanks[id.Role] >= rank
+}
+
+// RequireRole is RequireAuth for routes that need at least role. Callers
+// without it get a 403.
+func RequireRole(role Role, next http.HandlerFunc) http.HandlerFunc {
+	return RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := IdentityFromContext(r.Context())
+		if !id.Has(role) {
+			slog.Error("Role not allowed", "identity", id.Name, "role", id.Role, "required", role, "path", r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
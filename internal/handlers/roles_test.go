@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireRole(t *testing.T) {
+	t.Setenv("SHARED_SECRET", "legacy")
+	original := activeAPIKeys
+	defer func() {
+		activeAPIKeys = original
+	}()
+	if err := LoadAPIKeys("", "cron@viewer:viewer-key,runner@editor:editor-key,etl@ingester:ingester-key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		role       Role
+		secret     string
+		statusCode int
+	}{
+		{name: "viewer can check", role: RoleViewer, secret: "viewer-key", statusCode: http.StatusOK},
+		{name: "viewer can't transform", role: RoleEditor, secret: "viewer-key", statusCode: http.StatusForbidden},
+		{name: "editor can transform", role: RoleEditor, secret: "editor-key", statusCode: http.StatusOK},
+		{name: "editor can check", role: RoleViewer, secret: "editor-key", statusCode: http.StatusOK},
+		{name: "editor isn't an ingester", role: RoleIngester, secret: "editor-key", statusCode: http.StatusForbidden},
+		{name: "ingester can do everything", role: RoleIngester, secret: "ingester-key", statusCode: http.StatusOK},
+		{name: "shared secret keeps its access", role: RoleIngester, secret: "legacy", statusCode: http.StatusOK},
+		{name: "anonymous", role: RoleViewer, statusCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequireRole(tt.role, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("X-Secret", tt.secret)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthPolicyRoles(t *testing.T) {
+	policy, err := parseAuthPolicy([]byte(`{
+  "domains": ["lehigh.edu"],
+  "issuers": ["https://accounts.google.com"],
+  "roles": {"Curator@lehigh.edu": "ingester", "student@lehigh.edu": "editor"},
+  "default_role": "viewer"
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for email, role := range map[string]Role{
+		"curator@lehigh.edu": RoleIngester,
+		"Student@Lehigh.edu": RoleEditor,
+		"someone@lehigh.edu": RoleViewer,
+	} {
+		if got := policy.roleFor(email); got != role {
+			t.Errorf("expected %s to be %s, got %s", email, role, got)
+		}
+	}
+
+	// without a default role everyone can still transform, as before roles
+	policy, err = parseAuthPolicy([]byte(`{"domains":["lehigh.edu"],"issuers":["x"]}`))
+	if err != nil || policy.roleFor("someone@lehigh.edu") != RoleEditor {
+		t.Errorf("expected the default role to be editor, got %s (%v)", policy.roleFor("someone@lehigh.edu"), err)
+	}
+
+	if _, err := parseAuthPolicy([]byte(`{"domains":["lehigh.edu"],"issuers":["x"],"roles":{"a@lehigh.edu":"admin"}}`)); err == nil {
+		t.Fatal("expected an unknown role to be rejected")
+	}
+}
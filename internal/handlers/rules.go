@@ -0,0 +1,612 @@
+package handlers
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lehigh-university-libraries/fabricator/internal/contributor"
+	"github.com/lehigh-university-libraries/fabricator/internal/tgn"
+	edtf "github.com/sfomuseum/go-edtf/parser"
+)
+
+// rules.json is the default set of CheckMyWork rules. It can be replaced at
+// startup with FABRICATOR_CHECK_RULES so other sheet templates can be
+// validated without a code change.
+//
+//go:embed rules.json
+var defaultCheckRules []byte
+
+var (
+	doiPattern  = regexp.MustCompile(`^10\.\d{4,9}\/[-._;()/:A-Za-z0-9]+$`)
+	datePattern = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?$`)
+)
+
+// Validator checks a single cell value. It returns a message describing the
+// problem, or "" when the value is valid.
+type Validator interface {
+	Validate(value string, row checkRow) string
+}
+
+// multiValidator is implemented by validators that can find more than one
+// problem in a single value, e.g. a contributor with a bad relator and a
+// blank name.
+type multiValidator interface {
+	ValidateAll(value string, row checkRow) []string
+}
+
+// candidateValidator is implemented by validators that can suggest
+// replacement values for a value they rejected.
+type candidateValidator interface {
+	Candidates(value string, row checkRow) []tgn.Candidate
+}
+
+// checkRule is one entry in the rules config.
+type checkRule struct {
+	ID      string `json:"id"`
+	Column  string `json:"column"`
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+	// Severity is error (the default), warning or info. Only errors block ingest.
+	Severity string `json:"severity,omitempty"`
+	// Fix is a suggestion shown alongside the message.
+	Fix string `json:"fix,omitempty"`
+
+	// Values lists the allowed values for enum rules.
+	Values []string `json:"values,omitempty"`
+	// Pattern is the regular expression regex rules must match.
+	Pattern string `json:"pattern,omitempty"`
+	// Max is the longest value max-length rules allow.
+	Max int `json:"max,omitempty"`
+	// When lists the conditions that must all hold for required-when rules.
+	When []ruleCondition `json:"when,omitempty"`
+}
+
+// structural rules reported by CheckMyWork itself rather than the rules config.
+var (
+	noRowsRule = checkRule{
+		ID:  "no-rows",
+		Fix: "Add at least one row below the header row",
+	}
+	rowTooWideRule = checkRule{
+		ID:  "row-too-wide",
+		Fix: "Remove values to the right of the last column, or give the column a header",
+	}
+)
+
+func (rule checkRule) severity() string {
+	if rule.Severity == "" {
+		return severityError
+	}
+	return rule.Severity
+}
+
+// ruleCondition compares another column in the same row.
+type ruleCondition struct {
+	Column string   `json:"column"`
+	In     []string `json:"in,omitempty"`
+	NotIn  []string `json:"not_in,omitempty"`
+	Empty  *bool    `json:"empty,omitempty"`
+}
+
+func (c ruleCondition) holds(row checkRow) bool {
+	value := row.value(c.Column)
+	if c.Empty != nil && (value == "") != *c.Empty {
+		return false
+	}
+	if len(c.In) > 0 && !strInSlice(value, c.In) {
+		return false
+	}
+	if len(c.NotIn) > 0 && strInSlice(value, c.NotIn) {
+		return false
+	}
+	return true
+}
+
+// validatorKind describes how to build a Validator for a rule kind. Blank
+// validators run when a cell is empty, all others run once for each
+// " ; " separated value in a non-empty cell.
+type validatorKind struct {
+	blank bool
+	build func(rule checkRule) (Validator, error)
+}
+
+var validatorRegistry = map[string]validatorKind{
+	"required-on-create": {blank: true, build: newRequiredOnCreateValidator},
+	"required-when":      {blank: true, build: newRequiredWhenValidator},
+	"integer":            {build: newIntegerValidator},
+	"edtf":               {build: newEDTFValidator},
+	"doi":                {build: newDOIValidator},
+	"url":                {build: newURLValidator},
+	"enum":               {build: newEnumValidator},
+	"regex":              {build: newRegexValidator},
+	"max-length":         {build: newMaxLengthValidator},
+	"unique":             {build: newUniqueValidator},
+	"node-exists":        {build: newNodeExistsValidator},
+	"parent-id":          {build: newParentIDValidator},
+	"rights-statement":   {build: newRightsStatementValidator},
+	"contributor":        {build: newContributorValidator},
+	"file-exists":        {build: newFileExistsValidator},
+	"media-extension":    {build: newMediaExtensionValidator},
+	"tgn":                {build: newTGNValidator},
+}
+
+type compiledRule struct {
+	checkRule
+	Validator
+}
+
+// messages returns every problem the rule finds with value.
+func (rule compiledRule) messages(value string, row checkRow) []string {
+	if v, ok := rule.Validator.(multiValidator); ok {
+		return v.ValidateAll(value, row)
+	}
+	if msg := rule.Validate(value, row); msg != "" {
+		return []string{msg}
+	}
+	return nil
+}
+
+// checkRules indexes compiled rules by column, preserving config order.
+type checkRules struct {
+	blank  map[string][]compiledRule
+	values map[string][]compiledRule
+}
+
+var activeCheckRules = mustParseCheckRules(defaultCheckRules)
+
+// LoadCheckRules replaces the built-in CheckMyWork rules with the JSON file at path.
+// An empty path keeps the defaults. It should be called once at startup before
+// any check is served.
+func LoadCheckRules(path string) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read check rules %s: %w", path, err)
+	}
+	rules, err := parseCheckRules(raw)
+	if err != nil {
+		return fmt.Errorf("invalid check rules %s: %w", path, err)
+	}
+	activeCheckRules = rules
+
+	return nil
+}
+
+func mustParseCheckRules(raw []byte) checkRules {
+	rules, err := parseCheckRules(raw)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+func parseCheckRules(raw []byte) (checkRules, error) {
+	var list []checkRule
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return checkRules{}, err
+	}
+
+	rules := checkRules{
+		blank:  map[string][]compiledRule{},
+		values: map[string][]compiledRule{},
+	}
+	ids := map[string]bool{}
+	for i, rule := range list {
+		if rule.ID == "" {
+			return checkRules{}, fmt.Errorf("rule %d: id is required", i)
+		}
+		if ids[rule.ID] {
+			return checkRules{}, fmt.Errorf("rule %d: duplicate id %q", i, rule.ID)
+		}
+		ids[rule.ID] = true
+		if rule.Column == "" {
+			return checkRules{}, fmt.Errorf("rule %q: column is required", rule.ID)
+		}
+		switch rule.severity() {
+		case severityError, severityWarning, severityInfo:
+		default:
+			return checkRules{}, fmt.Errorf("rule %q: unknown severity %q", rule.ID, rule.Severity)
+		}
+		kind, ok := validatorRegistry[rule.Kind]
+		if !ok {
+			return checkRules{}, fmt.Errorf("rule %q: unknown kind %q", rule.ID, rule.Kind)
+		}
+		v, err := kind.build(rule)
+		if err != nil {
+			return checkRules{}, fmt.Errorf("rule %q: %w", rule.ID, err)
+		}
+
+		compiled := compiledRule{checkRule: rule, Validator: v}
+		if kind.blank {
+			rules.blank[rule.Column] = append(rules.blank[rule.Column], compiled)
+		} else {
+			rules.values[rule.Column] = append(rules.values[rule.Column], compiled)
+		}
+	}
+
+	return rules, nil
+}
+
+// checkState is shared by every validator for a single CheckMyWork request.
+type checkState struct {
+	ctx           context.Context
+	header        []string
+	relators      []string
+	uploadIds     map[string]bool
+	seen          map[string]map[string]bool
+	urlCheckCache *sync.Map
+	tgnChecked    map[string]string
+	tgnCandidates map[string][]tgn.Candidate
+}
+
+func newCheckState(ctx context.Context, header []string) *checkState {
+	return &checkState{
+		ctx:           ctx,
+		header:        header,
+		relators:      validRelators(),
+		uploadIds:     map[string]bool{},
+		seen:          map[string]map[string]bool{},
+		urlCheckCache: &sync.Map{},
+		tgnChecked:    map[string]string{},
+		tgnCandidates: map[string][]tgn.Candidate{},
+	}
+}
+
+// checkRow is the row a cell being validated belongs to.
+type checkRow struct {
+	*checkState
+	row []string
+}
+
+func (r checkRow) value(column string) string {
+	return ColumnValue(column, r.header, r.row)
+}
+
+func messageOr(rule checkRule, fallback string) string {
+	if rule.Message != "" {
+		return rule.Message
+	}
+	return fallback
+}
+
+type staticValidator struct {
+	message string
+	valid   func(value string) bool
+}
+
+func (v staticValidator) Validate(value string, _ checkRow) string {
+	if v.valid(value) {
+		return ""
+	}
+	return v.message
+}
+
+type requiredOnCreateValidator struct {
+	message string
+}
+
+func newRequiredOnCreateValidator(rule checkRule) (Validator, error) {
+	return requiredOnCreateValidator{message: messageOr(rule, "Missing value")}, nil
+}
+
+// Validate only requires the column on create; rows with a node ID are updates.
+func (v requiredOnCreateValidator) Validate(_ string, row checkRow) string {
+	if row.value("Node ID") == "" {
+		return v.message
+	}
+	return ""
+}
+
+type requiredWhenValidator struct {
+	message string
+	when    []ruleCondition
+}
+
+func newRequiredWhenValidator(rule checkRule) (Validator, error) {
+	if len(rule.When) == 0 {
+		return nil, fmt.Errorf("when is required for required-when rules")
+	}
+	return requiredWhenValidator{message: messageOr(rule, "Missing value"), when: rule.When}, nil
+}
+
+func (v requiredWhenValidator) Validate(_ string, row checkRow) string {
+	for _, c := range v.when {
+		if !c.holds(row) {
+			return ""
+		}
+	}
+	return v.message
+}
+
+func newIntegerValidator(rule checkRule) (Validator, error) {
+	return staticValidator{
+		message: messageOr(rule, "Must be an integer"),
+		valid: func(value string) bool {
+			_, err := strconv.Atoi(value)
+			return err == nil
+		},
+	}, nil
+}
+
+func newEDTFValidator(rule checkRule) (Validator, error) {
+	return staticValidator{
+		message: messageOr(rule, "Invalid EDTF value"),
+		valid: func(value string) bool {
+			if datePattern.MatchString(value) || edtf.IsValid(value) {
+				return true
+			}
+			slog.Error("Invalid EDTF value", "cell", value)
+			return false
+		},
+	}, nil
+}
+
+func newDOIValidator(rule checkRule) (Validator, error) {
+	return staticValidator{
+		message: messageOr(rule, "Invalid DOI"),
+		valid:   doiPattern.MatchString,
+	}, nil
+}
+
+func newURLValidator(rule checkRule) (Validator, error) {
+	return staticValidator{
+		message: messageOr(rule, "Invalid URL"),
+		valid: func(value string) bool {
+			parsedURL, err := url.ParseRequestURI(value)
+			return err == nil && (parsedURL.Scheme != "" || parsedURL.Host != "")
+		},
+	}, nil
+}
+
+func newEnumValidator(rule checkRule) (Validator, error) {
+	if len(rule.Values) == 0 {
+		return nil, fmt.Errorf("values is required for enum rules")
+	}
+	return staticValidator{
+		message: messageOr(rule, fmt.Sprintf("Invalid value. Must be one of: %s", strings.Join(rule.Values, ", "))),
+		valid: func(value string) bool {
+			return strInSlice(value, rule.Values)
+		},
+	}, nil
+}
+
+func newRegexValidator(rule checkRule) (Validator, error) {
+	if rule.Pattern == "" {
+		return nil, fmt.Errorf("pattern is required for regex rules")
+	}
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return staticValidator{
+		message: messageOr(rule, "Invalid value"),
+		valid:   re.MatchString,
+	}, nil
+}
+
+func newMaxLengthValidator(rule checkRule) (Validator, error) {
+	if rule.Max <= 0 {
+		return nil, fmt.Errorf("max is required for max-length rules")
+	}
+	return staticValidator{
+		message: messageOr(rule, fmt.Sprintf("Longer than %d characters", rule.Max)),
+		valid: func(value string) bool {
+			return len(value) <= rule.Max
+		},
+	}, nil
+}
+
+type uniqueValidator struct {
+	id      string
+	message string
+}
+
+func newUniqueValidator(rule checkRule) (Validator, error) {
+	return uniqueValidator{id: rule.ID, message: messageOr(rule, "Duplicate value")}, nil
+}
+
+func (v uniqueValidator) Validate(value string, row checkRow) string {
+	seen, ok := row.seen[v.id]
+	if !ok {
+		seen = map[string]bool{}
+		row.seen[v.id] = seen
+	}
+	if seen[value] {
+		return v.message
+	}
+	seen[value] = true
+	return ""
+}
+
+type nodeExistsValidator struct {
+	message string
+}
+
+func newNodeExistsValidator(rule checkRule) (Validator, error) {
+	return nodeExistsValidator{message: messageOr(rule, "Could not find node ID {value}")}, nil
+}
+
+// Validate skips values that are not integers so an integer rule on the same
+// column can report them.
+func (v nodeExistsValidator) Validate(value string, row checkRow) string {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return ""
+	}
+	url := fmt.Sprintf("%s/node/%d?_format=json", os.Getenv("ISLE_SITE_URL"), id)
+	if checkURL(url, row.urlCheckCache) {
+		return ""
+	}
+	return strings.ReplaceAll(v.message, "{value}", strconv.Itoa(id))
+}
+
+type parentIDValidator struct{}
+
+func newParentIDValidator(checkRule) (Validator, error) {
+	return parentIDValidator{}, nil
+}
+
+// Validate makes sure the parent ID matches an upload ID somewhere in the
+// spreadsheet, including rows further down.
+func (parentIDValidator) Validate(value string, row checkRow) string {
+	if _, ok := row.uploadIds[value]; !ok {
+		return "Unknown parent ID"
+	}
+	if value == row.value("Upload ID") {
+		return "Upload ID and parent ID can not be equal"
+	}
+	return ""
+}
+
+func newRightsStatementValidator(rule checkRule) (Validator, error) {
+	return staticValidator{
+		message: messageOr(rule, "Invalid Rights Statement"),
+		valid: func(value string) bool {
+			_, ok := rightsStatementURI(value)
+			return ok
+		},
+	}, nil
+}
+
+type contributorValidator struct{}
+
+func newContributorValidator(checkRule) (Validator, error) {
+	return contributorValidator{}, nil
+}
+
+func (v contributorValidator) Validate(value string, row checkRow) string {
+	msgs := v.ValidateAll(value, row)
+	if len(msgs) == 0 {
+		return ""
+	}
+	return msgs[0]
+}
+
+func (contributorValidator) ValidateAll(value string, row checkRow) []string {
+	var msgs []string
+	var c contributor.Contributor
+	err := json.Unmarshal([]byte(value), &c)
+	if err != nil {
+		msgs = append(msgs, "Contributor not in proper format")
+	}
+	name, err := c.ParseName()
+	if errors.Is(err, contributor.ErrPartialName) {
+		return append(msgs, "Contributor relator and vocab must be given together")
+	}
+	if err != nil {
+		return append(msgs, "Contributor name not in proper format")
+	}
+	if strings.TrimSpace(name.Display) == "" {
+		msgs = append(msgs, "Blank names are not allowed")
+	}
+	if !strInSlice(name.Relator, row.relators) {
+		msgs = append(msgs, fmt.Sprintf("Invalid relator: %s", name.Relator))
+	}
+	if !strInSlice(name.Vocab, []string{"person", "corporate_body"}) {
+		msgs = append(msgs, fmt.Sprintf("Bad vocabulary ID for contributor: %s", name.Vocab))
+	} else if name.Vocab != "person" && (c.Status != "" || c.Email != "" || c.Institution != "" || c.InstitutionRor != "" || c.Orcid != "") {
+		msgs = append(msgs, "Additional fields can only be applied to people")
+	}
+	if c.Orcid != "" {
+		if _, err := contributor.NormalizeOrcid(c.Orcid); errors.Is(err, contributor.ErrOrcidChecksum) {
+			msgs = append(msgs, fmt.Sprintf("ORCiD check digit is wrong, check for a typo: %s", c.Orcid))
+		} else if err != nil {
+			msgs = append(msgs, fmt.Sprintf("Invalid ORCiD, expected 0000-0000-0000-0000 or https://orcid.org/0000-0000-0000-0000: %s", c.Orcid))
+		}
+	}
+	if c.InstitutionRor != "" {
+		if _, err := contributor.NormalizeRor(c.InstitutionRor); errors.Is(err, contributor.ErrRorChecksum) {
+			msgs = append(msgs, fmt.Sprintf("ROR ID checksum is wrong, check for a typo: %s", c.InstitutionRor))
+		} else if err != nil {
+			msgs = append(msgs, fmt.Sprintf("Invalid ROR ID, expected 0xxxxxx00 or https://ror.org/0xxxxxx00: %s", c.InstitutionRor))
+		}
+		if c.Institution == "" {
+			msgs = append(msgs, "A ROR ID needs the institution's name too")
+		}
+	}
+	return msgs
+}
+
+func newFileExistsValidator(rule checkRule) (Validator, error) {
+	return staticValidator{
+		message: messageOr(rule, "File does not exist in islandora_staging"),
+		valid: func(value string) bool {
+			return fileExists(workbenchMediaPath(value))
+		},
+	}, nil
+}
+
+type mediaExtensionValidator struct{}
+
+func newMediaExtensionValidator(checkRule) (Validator, error) {
+	return mediaExtensionValidator{}, nil
+}
+
+// Validate checks the file extension is allowed for the row's object model.
+// Missing files are left to a file-exists rule.
+func (mediaExtensionValidator) Validate(value string, row checkRow) string {
+	filename := workbenchMediaPath(value)
+	if !fileExists(filename) {
+		return ""
+	}
+	model := strings.TrimSpace(row.value("Object Model"))
+	if model == "" {
+		return ""
+	}
+	mediaType := workbenchMediaType(model)
+	if !isAllowedWorkbenchMediaExtension(filename, mediaType) {
+		return fmt.Sprintf("File extension is not allowed for object model %s", mediaType)
+	}
+	return ""
+}
+
+type tgnValidator struct {
+	message string
+}
+
+func newTGNValidator(rule checkRule) (Validator, error) {
+	return tgnValidator{message: messageOr(rule, "Unable to get TGN")}, nil
+}
+
+func (v tgnValidator) Validate(value string, row checkRow) string {
+	if msg, ok := row.tgnChecked[value]; ok {
+		return msg
+	}
+	msg := ""
+	if _, err := tgn.DefaultClient.Resolve(row.ctx, value); errors.Is(err, tgn.ErrInvalidURI) {
+		msg = "Invalid TGN URI"
+	} else if err != nil {
+		msg = v.message
+	}
+	row.tgnChecked[value] = msg
+	return msg
+}
+
+// Candidates searches TGN when the value looks like a place name, e.g.
+// "Bethlehem, PA", rather than a broken TGN URI.
+func (tgnValidator) Candidates(value string, row checkRow) []tgn.Candidate {
+	if strings.Contains(value, "/tgn/") {
+		return nil
+	}
+	if candidates, ok := row.tgnCandidates[value]; ok {
+		return candidates
+	}
+	candidates, err := tgn.DefaultClient.Search(row.ctx, value, 0)
+	if err != nil {
+		slog.Warn("Unable to search TGN", "name", value, "err", err)
+	}
+	row.tgnCandidates[value] = candidates
+	return candidates
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCheckRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{
+			name: "valid rules",
+			raw:  `[{"id":"title-length","column":"Title","kind":"max-length","max":10}]`,
+		},
+		{
+			name:    "missing id",
+			raw:     `[{"column":"Title","kind":"integer"}]`,
+			wantErr: "id is required",
+		},
+		{
+			name:    "duplicate id",
+			raw:     `[{"id":"a","column":"Title","kind":"integer"},{"id":"a","column":"PPI","kind":"integer"}]`,
+			wantErr: `duplicate id "a"`,
+		},
+		{
+			name:    "unknown kind",
+			raw:     `[{"id":"a","column":"Title","kind":"nope"}]`,
+			wantErr: `unknown kind "nope"`,
+		},
+		{
+			name:    "bad regex",
+			raw:     `[{"id":"a","column":"Title","kind":"regex","pattern":"("}]`,
+			wantErr: "invalid pattern",
+		},
+		{
+			name:    "enum without values",
+			raw:     `[{"id":"a","column":"Title","kind":"enum"}]`,
+			wantErr: "values is required",
+		},
+		{
+			name:    "required-when without conditions",
+			raw:     `[{"id":"a","column":"Title","kind":"required-when"}]`,
+			wantErr: "when is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCheckRules([]byte(tt.raw))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// Every shipped rule tells the person filling in the sheet how to fix it.
+func TestDefaultCheckRulesHaveFixes(t *testing.T) {
+	var rules []checkRule
+	if err := json.Unmarshal(defaultCheckRules, &rules); err != nil {
+		t.Fatalf("failed decoding default rules: %v", err)
+	}
+	for _, rule := range rules {
+		if strings.TrimSpace(rule.Fix) == "" {
+			t.Errorf("rule %q has no fix", rule.ID)
+		}
+	}
+}
+
+func TestLoadCheckRulesCustomTemplate(t *testing.T) {
+	original := activeCheckRules
+	defer func() {
+		activeCheckRules = original
+	}()
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `[
+  {"id": "accession-required", "column": "Accession Number", "kind": "required-on-create", "message": "Accession number is required"},
+  {"id": "accession-format", "column": "Accession Number", "kind": "regex", "pattern": "^\\d{4}\\.\\d+$", "message": "Accession numbers look like 2024.12"},
+  {"id": "department", "column": "Department", "kind": "enum", "values": ["Art", "History"]},
+  {"id": "note-length", "column": "Note", "kind": "max-length", "max": 5, "severity": "warning", "fix": "Shorten the note"},
+  {
+    "id": "image-needs-note",
+    "column": "Note",
+    "kind": "required-when",
+    "when": [{"column": "Object Model", "in": ["Image"]}],
+    "message": "Images need a note"
+  }
+]`
+	if err := os.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatalf("failed writing rules: %v", err)
+	}
+	if err := LoadCheckRules(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	os.Setenv("SHARED_SECRET", "foo")
+	body, err := json.Marshal([][]string{
+		{"Accession Number", "Department", "Note", "Object Model"},
+		{"2024.12", "Art", "short", "Image"},
+		{"", "Science", "too long", "Image"},
+		{"24-12", "History", "", "Image"},
+		{"2024.13", "History", "", "Audio"},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal body: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/workbench/check", bytes.NewReader(body))
+	req.Header.Set("X-Secret", "foo")
+	rec := httptest.NewRecorder()
+
+	CheckMyWork(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	expected := `{"findings":[` +
+		`{"cell":"A3","column":"Accession Number","severity":"error","rule":"accession-required","message":"Accession number is required"},` +
+		`{"cell":"B3","column":"Department","severity":"error","rule":"department","message":"Invalid value. Must be one of: Art, History"},` +
+		`{"cell":"C3","column":"Note","severity":"warning","rule":"note-length","message":"Longer than 5 characters","fix":"Shorten the note"},` +
+		`{"cell":"A4","column":"Accession Number","severity":"error","rule":"accession-format","message":"Accession numbers look like 2024.12"},` +
+		`{"cell":"C4","column":"Note","severity":"error","rule":"image-needs-note","message":"Images need a note"}` +
+		`],"errors":4,"warnings":1}`
+	if rec.Body.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, rec.Body.String())
+	}
+
+	// the legacy flat map only carries errors so warnings never block old consumers
+	req = httptest.NewRequest(http.MethodPost, "/workbench/check", bytes.NewReader(body))
+	req.Header.Set("X-Secret", "foo")
+	req.Header.Set("Accept", legacyCheckMediaType)
+	rec = httptest.NewRecorder()
+
+	CheckMyWork(rec, req)
+
+	expected = `{"A3":"Accession number is required","A4":"Accession numbers look like 2024.12","B3":"Invalid value. Must be one of: Art, History","C4":"Images need a note"}`
+	if rec.Body.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, rec.Body.String())
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"container/list"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Defaults for the term cache shared by every transform in the process.
+const (
+	DefaultTermCacheTTL         = time.Hour
+	DefaultTermCacheNegativeTTL = 5 * time.Minute
+	DefaultTermCacheSize        = 10000
+)
+
+// termCache remembers /term_from_term_name lookups across transforms so
+// frequent authors and institutions aren't looked up for every sheet. Misses
+// are kept for a shorter time than hits and are looked up again before a term
+// is created, and the least recently used entry is evicted once the cache is
+// full.
+type termCache struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	negativeTTL time.Duration
+	size        int
+	entries     map[string]*list.Element
+	lru         *list.List
+	now         func() time.Time
+}
+
+type termCacheEntry struct {
+	key     string
+	tid     int
+	name    string
+	found   bool
+	expires time.Time
+}
+
+var sharedTermCache = newTermCache(DefaultTermCacheTTL, DefaultTermCacheNegativeTTL, DefaultTermCacheSize)
+
+func newTermCache(ttl, negativeTTL time.Duration, size int) *termCache {
+	return &termCache{
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		size:        size,
+		entries:     map[string]*list.Element{},
+		lru:         list.New(),
+		now:         time.Now,
+	}
+}
+
+// ConfigureTermCache replaces the term cache shared by transforms. A zero ttl
+// or size disables caching. It should be called once at startup.
+func ConfigureTermCache(ttl, negativeTTL time.Duration, size int) {
+	sharedTermCache = newTermCache(ttl, negativeTTL, size)
+}
+
+// termCacheKey identifies a lookup against a Drupal site.
+func termCacheKey(baseURL string, params url.Values) string {
+	return baseURL + "?" + params.Encode()
+}
+
+func (c *termCache) get(key string) (termCacheEntry, bool) {
+	if c == nil {
+		return termCacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return termCacheEntry{}, false
+	}
+	entry := el.Value.(termCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(el)
+		return termCacheEntry{}, false
+	}
+	c.lru.MoveToFront(el)
+	return entry, true
+}
+
+// set caches a lookup's result, or that it found nothing when found is false.
+func (c *termCache) set(key string, tid int, name string, found bool) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+	ttl := c.ttl
+	if !found {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	entry := termCacheEntry{key: key, tid: tid, name: name, found: found, expires: c.now().Add(ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// forgetTerm drops every lookup that resolved to tid, e.g. after it was
+// deleted.
+func (c *termCache) forgetTerm(tid int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, el := range c.entries {
+		if entry := el.Value.(termCacheEntry); entry.found && entry.tid == tid {
+			c.remove(el)
+		}
+	}
+}
+
+// flush empties the cache and returns how many entries it held.
+func (c *termCache) flush() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.lru.Len()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+	return n
+}
+
+func (c *termCache) remove(el *list.Element) {
+	delete(c.entries, el.Value.(termCacheEntry).key)
+	c.lru.Remove(el)
+}
+
+// FlushTermCache empties the shared term cache, e.g. after catalogers merge
+// terms in Drupal. DELETE /admin/term-cache
+func FlushTermCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	n := sharedTermCache.flush()
+	id, _ := IdentityFromContext(r.Context())
+	slog.Info("Flushed term cache", "entries", n, "identity", id.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
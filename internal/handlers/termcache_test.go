@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lehigh-university-libraries/fabricator/internal/contributor"
+)
+
+func TestTermCache(t *testing.T) {
+	now := time.Now()
+	c := newTermCache(time.Hour, time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	c.set("hit", 62, "Lehigh University", true)
+	c.set("miss", 0, "", false)
+
+	now = now.Add(2 * time.Minute)
+	if entry, ok := c.get("hit"); !ok || entry.tid != 62 {
+		t.Fatalf("expected the hit to still be cached, got %+v %v", entry, ok)
+	}
+	if _, ok := c.get("miss"); ok {
+		t.Fatal("expected the miss to expire before the hit")
+	}
+
+	// "hit" was used more recently than "other" so "other" goes first
+	c.set("other", 7, "Other", true)
+	c.get("hit")
+	c.set("third", 8, "Third", true)
+	if _, ok := c.get("other"); ok {
+		t.Fatal("expected the least recently used entry to be evicted")
+	}
+	if len(c.entries) != 2 || c.lru.Len() != 2 {
+		t.Fatalf("expected the cache to hold 2 entries, got %d", len(c.entries))
+	}
+
+	c.forgetTerm(62)
+	if _, ok := c.get("hit"); ok {
+		t.Fatal("expected a forgotten term to be dropped")
+	}
+	if n := c.flush(); n != 1 {
+		t.Fatalf("expected flush to drop 1 entry, got %d", n)
+	}
+	if _, ok := c.get("third"); ok {
+		t.Fatal("expected flush to empty the cache")
+	}
+}
+
+func TestTermCacheSharedAcrossTransforms(t *testing.T) {
+	original := sharedTermCache
+	defer func() {
+		sharedTermCache = original
+	}()
+	ConfigureTermCache(time.Hour, time.Minute, 100)
+
+	var lookups, creates atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			lookups.Add(1)
+			if r.URL.Query().Get("vocab") == "corporate_body" {
+				_, _ = w.Write([]byte(`[{"tid":[{"value":62}]}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[]`))
+		case "/taxonomy/term":
+			creates.Add(1)
+			_, _ = w.Write([]byte(`{"tid":[{"value":500}]}`))
+		case "/jsonapi/taxonomy_term/person":
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+	resolve := func() string {
+		t.Helper()
+		resolver := newDrupalTermResolver()
+		got, err := resolver.resolveContributor(contributor.Contributor{
+			Name:        "relators:aut:person:New, Nia",
+			Institution: "Lehigh University",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resolver.shareCreated()
+		return got
+	}
+
+	// the first transform looks up both and creates the person, the second
+	// reuses the institution and finds the person it created
+	for range 3 {
+		if got := resolve(); got != "relators:aut:500" {
+			t.Fatalf("unexpected resolved contributor: %s", got)
+		}
+	}
+	if lookups.Load() != 2 || creates.Load() != 1 {
+		t.Fatalf("expected 2 lookups and 1 create, got %d and %d", lookups.Load(), creates.Load())
+	}
+
+	rec := httptest.NewRecorder()
+	FlushTermCache(rec, httptest.NewRequest(http.MethodDelete, "/admin/term-cache", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rec.Code)
+	}
+	resolve()
+	if lookups.Load() != 4 {
+		t.Fatalf("expected a flushed cache to look terms up again, got %d lookups", lookups.Load())
+	}
+
+	rec = httptest.NewRecorder()
+	FlushTermCache(rec, httptest.NewRequest(http.MethodGet, "/admin/term-cache", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status 405, got %d", rec.Code)
+	}
+}
+
+// A term created in Drupal after a transform missed it is found, not created
+// again, by the next ingester.
+func TestTermCacheMissRecheckedBeforeCreate(t *testing.T) {
+	original := sharedTermCache
+	defer func() {
+		sharedTermCache = original
+	}()
+	ConfigureTermCache(time.Hour, time.Minute, 100)
+
+	var exists atomic.Bool
+	var lookups, creates atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			lookups.Add(1)
+			if exists.Load() {
+				_, _ = w.Write([]byte(`[{"tid":[{"value":77}]}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[]`))
+		case "/taxonomy/term":
+			creates.Add(1)
+			_, _ = w.Write([]byte(`{"tid":[{"value":500}]}`))
+		case "/jsonapi/taxonomy_term/person":
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+	nia := contributor.Contributor{Name: "relators:aut:person:New, Nia"}
+	editor := newDrupalTermResolver()
+	editor.lookupOnly = true
+	if _, err := editor.resolveContributor(nia); !errors.Is(err, errTermNotCreated) {
+		t.Fatalf("expected the editor's lookup to miss, got %v", err)
+	}
+
+	// a cataloger creates the term before the sheet is transformed again
+	exists.Store(true)
+	editor = newDrupalTermResolver()
+	editor.lookupOnly = true
+	if _, err := editor.resolveContributor(nia); !errors.Is(err, errTermNotCreated) {
+		t.Fatalf("expected the editor to reuse the cached miss, got %v", err)
+	}
+	got, err := newDrupalTermResolver().resolveContributor(nia)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "relators:aut:77" || creates.Load() != 0 {
+		t.Fatalf("expected the ingester to find term 77, got %s after %d creates", got, creates.Load())
+	}
+	if lookups.Load() != 2 {
+		t.Fatalf("expected 2 lookups, got %d", lookups.Load())
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lehigh-university-libraries/fabricator/internal/tgn"
+)
+
+// maxTGNSearchLimit caps how many candidates a single search can ask for.
+const maxTGNSearchLimit = 20
+
+// SearchTGN suggests Getty TGN places for a name typed into the sheet, e.g.
+// GET /tgn/search?q=Bethlehem, PA
+func SearchTGN(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "Missing q parameter", http.StatusBadRequest)
+		return
+	}
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxTGNSearchLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxTGNSearchLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	candidates, err := tgn.DefaultClient.Search(r.Context(), q, limit)
+	if err != nil {
+		slog.Error("Unable to search TGN", "q", q, "err", err)
+		http.Error(w, "Unable to search TGN", http.StatusBadGateway)
+		return
+	}
+	if candidates == nil {
+		candidates = []tgn.Candidate{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(candidates); err != nil {
+		slog.Error("Error writing JSON response", "err", err)
+	}
+}
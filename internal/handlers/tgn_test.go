@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lehigh-university-libraries/fabricator/internal/tgn"
+)
+
+// useGettyStandIn points tgn.DefaultClient at a server that finds Bethlehem,
+// PA for any search and knows nothing else.
+func useGettyStandIn(t *testing.T) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sparql.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/sparql-results+json")
+		fmt.Fprint(w, `{"results":{"bindings":[{
+			"subject":{"value":"http://vocab.getty.edu/tgn/7013416"},
+			"term":{"value":"Bethlehem"},
+			"parents":{"value":"Pennsylvania, United States"},
+			"type":{"value":"city"}
+		}]}}`)
+	})
+	mux.HandleFunc("/tgn/7013416.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"http://vocab.getty.edu/tgn/7013416","_label":"Bethlehem",
+			"classified_as":[{"id":"http://vocab.getty.edu/aat/300008389","_label":"cities"}]}`)
+	})
+	mux.HandleFunc("/", http.NotFound)
+	server := httptest.NewServer(mux)
+
+	original := tgn.DefaultClient
+	client := tgn.NewClient()
+	client.BaseURL = server.URL
+	client.MaxRetries = 0
+	client.Cache = tgn.NewCache(time.Hour)
+	tgn.DefaultClient = client
+	t.Cleanup(func() {
+		tgn.DefaultClient = original
+		server.Close()
+	})
+}
+
+func TestSearchTGN(t *testing.T) {
+	useGettyStandIn(t)
+	os.Setenv("SHARED_SECRET", "foo")
+
+	tests := []struct {
+		name       string
+		method     string
+		query      string
+		statusCode int
+	}{
+		{name: "search", method: http.MethodGet, query: "?q=Bethlehem,+PA", statusCode: http.StatusOK},
+		{name: "missing name", method: http.MethodGet, query: "", statusCode: http.StatusBadRequest},
+		{name: "bad limit", method: http.MethodGet, query: "?q=Bethlehem&limit=100", statusCode: http.StatusBadRequest},
+		{name: "wrong method", method: http.MethodPost, query: "?q=Bethlehem", statusCode: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/tgn/search"+tt.query, nil)
+			req.Header.Set("X-Secret", "foo")
+			rec := httptest.NewRecorder()
+
+			SearchTGN(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var candidates []tgn.Candidate
+			if err := json.Unmarshal(rec.Body.Bytes(), &candidates); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if len(candidates) != 1 || candidates[0].Location == nil || candidates[0].Location.City != "Bethlehem" {
+				t.Fatalf("unexpected candidates %+v", candidates)
+			}
+		})
+	}
+}
+
+func TestCheckMyWorkSuggestsTGN(t *testing.T) {
+	useGettyStandIn(t)
+	os.Setenv("SHARED_SECRET", "foo")
+
+	body, err := json.Marshal([][]string{
+		{"Title", "Object Model", "Full Title", "Hierarchical Geographic (Getty TGN)"},
+		{"foo", "Image", "foo", "Bethlehem, PA"},
+		{"foo", "Image", "foo", "http://vocab.getty.edu/tgn/404"},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal body: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/workbench/check", bytes.NewReader(body))
+	req.Header.Set("X-Secret", "foo")
+	rec := httptest.NewRecorder()
+
+	CheckMyWork(rec, req)
+
+	var resp checkResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", rec.Body.String(), err)
+	}
+	if len(resp.Findings) != 2 {
+		t.Fatalf("expected 2 findings, got %s", rec.Body.String())
+	}
+	name := resp.Findings[0]
+	if name.Cell != "D2" || len(name.Candidates) != 1 || name.Candidates[0].URI != "http://vocab.getty.edu/tgn/7013416" {
+		t.Fatalf("expected a Bethlehem candidate for D2, got %+v", name)
+	}
+	// a broken URI isn't a place name, so there's nothing to search for
+	if uri := resp.Findings[1]; uri.Cell != "D3" || len(uri.Candidates) != 0 {
+		t.Fatalf("expected no candidates for D3, got %+v", uri)
+	}
+}
@@ -5,28 +5,78 @@ import (
 	"bytes"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
-	"reflect"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/lehigh-university-libraries/fabricator/internal/contributor"
 	"github.com/lehigh-university-libraries/fabricator/internal/tgn"
-	"github.com/lehigh-university-libraries/go-islandora/workbench"
 )
 
 func TransformCsv(w http.ResponseWriter, r *http.Request) {
-	headers, rows, err := readCSVWithJSONTags(r)
+	dryRun := false
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	workbenchTerms := false
+	switch r.URL.Query().Get("terms") {
+	case "", "create":
+	case "workbench":
+		workbenchTerms = true
+	default:
+		http.Error(w, "terms must be create or workbench", http.StatusBadRequest)
+		return
+	}
+
+	resolver := newDrupalTermResolver()
+	resolver.dryRun = dryRun
+	resolver.workbenchTerms = workbenchTerms
+	// only ingesters may create terms, everyone else finds out what's missing
+	id, _ := IdentityFromContext(r.Context())
+	resolver.lookupOnly = !id.Has(RoleIngester)
+	resolver.identity = id.Name
+	resolver.sheet = r.URL.Query().Get("sheet")
+
+	// a failure part way through deletes the terms already created, so they
+	// aren't left in Drupal without any item linking to them
+	fail := func(msg string, code int) {
+		if orphans := resolver.rollback(); len(orphans) > 0 {
+			msg += "\nUnable to delete the terms created before the failure, they need to be removed by hand:"
+			for _, o := range orphans {
+				msg += fmt.Sprintf("\n%s/taxonomy/term/%d (%s %s)", resolver.baseURL, o.Tid, o.Vocab, o.Name)
+			}
+		}
+		http.Error(w, msg, code)
+	}
+
+	headers, rows, err := readCSVWithJSONTags(r, resolver)
+	var unknown *unknownTermsError
+	if errors.As(err, &unknown) {
+		slog.Info("Contributors need terms created by an ingester", "count", len(unknown.Terms))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		if err := json.NewEncoder(w).Encode(unknown); err != nil {
+			slog.Error("Failed to write unknown contributors", "err", err)
+		}
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to read CSV", "err", err)
-		http.Error(w, "Error parsing CSV", http.StatusBadRequest)
+		fail("Error parsing CSV", http.StatusBadRequest)
 		return
 	}
 
@@ -35,71 +85,115 @@ func TransformCsv(w http.ResponseWriter, r *http.Request) {
 	for header := range headers {
 		firstRow = append(firstRow, header)
 	}
-	target := targetCSVPath(headers)
-	file, err := os.Create(target)
-	if err != nil {
-		slog.Error("Failed to create file", "err", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
-	}
-	writer := csv.NewWriter(file)
 
-	// finally, write the header to the CSV
-	if err := writer.Write(firstRow); err != nil {
+	// build every CSV in memory so concurrent transforms never share a
+	// workspace and there is nothing left on disk when a request fails
+	var target bytes.Buffer
+	if err := writeWorkbenchCSV(&target, firstRow, rows); err != nil {
 		slog.Error("Failed to write record to CSV", "err", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		fail("Internal error", http.StatusInternalServerError)
 		return
 	}
 
-	// write the rows to the CSV
-	for _, row := range rows {
-		record := []string{}
-		for _, header := range firstRow {
-			record = append(record, strings.Join(row[header], "|"))
+	files := []zipEntry{
+		{name: targetCSVName(headers), body: target.Bytes()},
+	}
+	if dryRun {
+		var manifest bytes.Buffer
+		if err := writePendingTermsCSV(&manifest, resolver.pending); err != nil {
+			slog.Error("Failed to write pending terms", "err", err)
+			fail("Internal error", http.StatusInternalServerError)
+			return
 		}
-		if err := writer.Write(record); err != nil {
-			slog.Error("Failed to write record to CSV", "err", err)
-			http.Error(w, "Internal error", http.StatusInternalServerError)
+		files = append(files, zipEntry{name: pendingTermsCSVName, body: manifest.Bytes()})
+	}
+	if len(resolver.created) > 0 {
+		var created bytes.Buffer
+		if err := writeTermsCreatedCSV(&created, resolver.created); err != nil {
+			slog.Error("Failed to write created terms", "err", err)
+			fail("Internal error", http.StatusInternalServerError)
 			return
 		}
+		files = append(files, zipEntry{name: termsCreatedCSVName, body: created.Bytes()})
 	}
-	writer.Flush()
-	file.Close()
-
-	files := []string{
-		target,
+	if len(resolver.duplicates) > 0 {
+		var duplicates bytes.Buffer
+		if err := writePossibleDuplicatesCSV(&duplicates, resolver.duplicates); err != nil {
+			slog.Error("Failed to write possible duplicates", "err", err)
+			fail("Internal error", http.StatusInternalServerError)
+			return
+		}
+		files = append(files, zipEntry{name: possibleDuplicatesCSVName, body: duplicates.Bytes()})
+	}
+	if workbenchTerms {
+		agents, err := workbenchTermCSVs(resolver.pending)
+		if err != nil {
+			slog.Error("Failed to write workbench term CSVs", "err", err)
+			fail("Internal error", http.StatusInternalServerError)
+			return
+		}
+		files = append(files, agents...)
+	}
+	archive, err := buildZip(files)
+	if err != nil {
+		slog.Error("Failed to build zip", "err", err)
+		fail("Internal error", http.StatusInternalServerError)
+		return
 	}
+	resolver.shareCreated()
+
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", "attachment; filename=files.zip")
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	if _, err := w.Write(archive); err != nil {
+		slog.Error("Failed to write zip response", "err", err)
+	}
+}
 
-	zipWriter := zip.NewWriter(w)
-	defer zipWriter.Close()
+// zipEntry is a single file in the transform response archive.
+type zipEntry struct {
+	name string
+	body []byte
+}
 
-	// Iterate over each file path in the slice and add it to the zip archive
-	for _, filePath := range files {
-		file, err := os.Open(filePath)
-		if err != nil {
-			http.Error(w, "Error opening file: "+err.Error(), http.StatusInternalServerError)
-			return
+// writeWorkbenchCSV writes the header row followed by each row's values in
+// header order, joining multi-value cells with workbench's pipe delimiter.
+func writeWorkbenchCSV(out io.Writer, firstRow []string, rows []map[string][]string) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(firstRow); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, 0, len(firstRow))
+		for _, header := range firstRow {
+			record = append(record, strings.Join(row[header], "|"))
 		}
-
-		fileName := filepath.Base(filePath)
-		zipFile, err := zipWriter.Create(fileName)
-		if err != nil {
-			http.Error(w, "Error creating zip entry: "+err.Error(), http.StatusInternalServerError)
-			return
+		if err := writer.Write(record); err != nil {
+			return err
 		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
 
-		_, err = io.Copy(zipFile, file)
+// buildZip assembles the response archive in memory so a failure part way
+// through can still be reported with a proper status code.
+func buildZip(files []zipEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for _, file := range files {
+		zipFile, err := zipWriter.Create(file.name)
 		if err != nil {
-			http.Error(w, "Error writing to zip: "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("error creating zip entry %s: %w", file.name, err)
+		}
+		if _, err := zipFile.Write(file.body); err != nil {
+			return nil, fmt.Errorf("error writing zip entry %s: %w", file.name, err)
 		}
-		file.Close()
-		os.Remove(filePath)
-		w.(http.Flusher).Flush()
 	}
-
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // normalizedWorkbenchHeaders strips update-irrelevant template columns from node-based
@@ -129,232 +223,214 @@ func normalizedWorkbenchHeaders(headers map[string]bool) map[string]bool {
 	return normalized
 }
 
-// targetCSVPath derives the Workbench task from the normalized header set so update
+// targetCSVName derives the Workbench task from the normalized header set so update
 // sheets with template columns do not get misclassified as create or add_media jobs.
-func targetCSVPath(headers map[string]bool) string {
+func targetCSVName(headers map[string]bool) string {
 	headers = normalizedWorkbenchHeaders(headers)
 	if headers["node_id"] && headers["file"] {
-		return "/tmp/target.add_media.csv"
+		return "target.add_media.csv"
 	}
 	if headers["node_id"] {
-		return "/tmp/target.update.csv"
+		return "target.update.csv"
 	}
-	return "/tmp/target.csv"
+	return "target.csv"
 }
 
-func getJSONFieldName(tag string) string {
-	if commaIndex := strings.Index(tag, ","); commaIndex != -1 {
-		return tag[:commaIndex]
-	}
-	return tag
-}
-
-func readCSVWithJSONTags(r *http.Request) (map[string]bool, []map[string][]string, error) {
+// readCSVWithJSONTags maps a sheet's columns to workbench fields, resolving
+// contributors to taxonomy terms with resolver.
+func readCSVWithJSONTags(r *http.Request, resolver *drupalTermResolver) (map[string]bool, []map[string][]string, error) {
 	defer r.Body.Close()
-	re := regexp.MustCompile(`^\d+$`)
 	reader := csv.NewReader(r.Body)
 	headers, err := reader.Read()
 	if err != nil {
 		return nil, nil, err
 	}
 
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var rows []map[string][]string
 	newHeaders := map[string]bool{}
 
-	resolver := newDrupalTermResolver()
-	newCsv := &workbench.SheetsCsv{}
-	tgnCache := make(map[string]string)
-	tgnCoordsCache := make(map[string]string)
-	for {
-		record, err := reader.Read()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, nil, err
-		}
-
+	mappings := activeColumnMappings
+	resolver.prefetch(sheetContributors(headers, records, mappings))
+	for _, record := range records {
 		row := map[string][]string{}
-		v := reflect.ValueOf(newCsv).Elem()
-		t := v.Type()
-
 		for i, header := range headers {
-			header = strings.TrimSpace(header)
-			for j := 0; j < t.NumField(); j++ {
-				field := t.Field(j)
-				jsonTag := getJSONFieldName(field.Tag.Get("json"))
-				column := getJSONFieldName(field.Tag.Get("csv"))
-				if jsonTag != header && (header != "Contributor" || column != "field_linked_agent") {
-					continue
-				}
-				value := v.FieldByName(field.Name)
-				if !value.IsValid() || !value.CanSet() {
-					continue
-				}
-				if record[i] == "" {
-					continue
-				}
-				if column == "" {
-					return nil, nil, fmt.Errorf("unknown column: %s", jsonTag)
-				}
-				originalColumn := column
-
-				values := []string{}
-				hierGeoCoords := []string{}
-				for _, str := range strings.Split(record[i], " ; ") {
-					switch originalColumn {
-					case "field_linked_agent":
-						var c contributor.Contributor
-						err := json.Unmarshal([]byte(str), &c)
-						if err != nil {
-							return nil, nil, fmt.Errorf("error unmarshalling contributor: %s %v", str, err)
-						}
-						str, err = resolver.resolveContributor(c)
-						if err != nil {
-							return nil, nil, fmt.Errorf("error resolving contributor: %s %v", str, err)
-						}
-
-					case "field_add_coverpage", "published":
-						switch str {
-						case "Yes":
-							str = "1"
-						case "No":
-							str = "0"
-						default:
-							return nil, nil, fmt.Errorf("unknown %s: %s", jsonTag, str)
-						}
-					case "field_restriction_value", "field_local_restriction":
-						if str == "Local Restriction" || str == "1" {
-							str = "1"
-						} else {
-							str = "0"
-						}
-					case "id", "parent_id":
-						if !re.MatchString(str) {
-							return nil, nil, fmt.Errorf("unknown %s: %s", jsonTag, str)
-						}
-					case "field_weight", "node_id":
-						_, err := strconv.Atoi(str)
-						if err != nil {
-							return nil, nil, fmt.Errorf("unknown %s: %s", jsonTag, str)
-						}
-						str = strings.TrimLeft(str, "0")
-					case "field_subject_hierarchical_geo":
-						key := str
-						if cached, ok := tgnCache[key]; ok {
-							str = cached
-							if c := tgnCoordsCache[key]; c != "" {
-								hierGeoCoords = append(hierGeoCoords, c)
-							}
-							break
-						}
-
-						loc, err := tgn.GetLocationFromTGN(key)
-						if err != nil {
-							return nil, nil, fmt.Errorf("unknown TGN: %s %v", key, err)
-						}
-
-						locationJSON, err := json.Marshal(loc)
-						if err != nil {
-							return nil, nil, fmt.Errorf("error marshalling TGN: %s %v", key, err)
-						}
-						tgnCache[key] = string(locationJSON)
-						tgnCoordsCache[key] = loc.Coordinates
-						str = tgnCache[key]
-						if loc.Coordinates != "" {
-							hierGeoCoords = append(hierGeoCoords, loc.Coordinates)
-						}
-
-					case "field_rights":
-						uri, ok := rightsStatementURI(str)
-						if !ok {
-							return nil, nil, fmt.Errorf("unknown %s: %s", jsonTag, str)
-						}
-						str = uri
-					case "field_extent.attr0=page",
-						"field_extent.attr0=dimensions",
-						"field_extent.attr0=bytes",
-						"field_extent.attr0=minutes",
-						"field_abstract.attr0=description",
-						"field_abstract.attr0=abstract",
-						"field_note.attr0=preferred-citation",
-						"field_note.attr0=capture-device",
-						"field_note.attr0=ppi",
-						"field_note.attr0=collection",
-						"field_note.attr0=box",
-						"field_note.attr0=series",
-						"field_note.attr0=folder",
-						"field_part_detail.attr0=volume",
-						"field_part_detail.attr0=issue",
-						"field_part_detail.attr0=page",
-						"field_identifier.attr0=doi",
-						"field_identifier.attr0=uri",
-						"field_identifier.attr0=call-number",
-						"field_identifier.attr0=report-number":
-						components := strings.Split(originalColumn, ".attr0=")
-						column = components[0]
-						var payload map[string]string
-						if column == "field_part_detail" {
-							payload = map[string]string{"number": str, "type": components[1]}
-						} else {
-							payload = map[string]string{"value": str, "attr0": components[1]}
-						}
-						encoded, err := json.Marshal(payload)
-						if err != nil {
-							return nil, nil, fmt.Errorf("error encoding %s: %s %v", originalColumn, str, err)
-						}
-						str = string(encoded)
-					case "field_geographic_subject.vid=geographic_naf",
-						"field_geographic_subject.vid=geographic_local":
-						components := strings.Split(originalColumn, ".vid=")
-						column = components[0]
-						str = fmt.Sprintf("%s:%s", components[1], str)
-					case "field_related_item.title":
-						column = "field_related_item"
-						encoded, err := json.Marshal(map[string]string{"title": str})
-						if err != nil {
-							return nil, nil, fmt.Errorf("error encoding field_related_item.title: %s %v", str, err)
-						}
-						str = string(encoded)
-					case "field_related_item.identifier_type=issn":
-						column = "field_related_item"
-						encoded, err := json.Marshal(map[string]string{"type": "issn", "identifier": str})
-						if err != nil {
-							return nil, nil, fmt.Errorf("error encoding field_related_item issn: %s %v", str, err)
-						}
-						str = string(encoded)
-					case "file", "supplemental_file":
-						str = strings.ReplaceAll(str, `\`, `/`)
-						if len(str) > 7 && str[0:6] == "/home/" {
-							break
-						}
-						str = strings.TrimLeft(str, "/")
-						if len(str) > 3 && str[0:3] != "mnt" {
-							str = fmt.Sprintf("/mnt/islandora_staging/%s", str)
-						}
+			mapping, ok := mappings[strings.TrimSpace(header)]
+			if !ok || record[i] == "" {
+				continue
+			}
+
+			column := mapping.Field
+			values := []string{}
+			hierGeoCoords := []string{}
+			for _, str := range strings.Split(record[i], " ; ") {
+				switch mapping.Kind {
+				case columnKindContributor:
+					var c contributor.Contributor
+					err := json.Unmarshal([]byte(str), &c)
+					if err != nil {
+						return nil, nil, fmt.Errorf("error unmarshalling contributor: %s %v", str, err)
+					}
+					str, err = resolver.resolveContributor(c)
+					if errors.Is(err, errTermNotCreated) {
+						// keep going so every unknown contributor is reported at once
+						continue
+					}
+					if err != nil {
+						return nil, nil, fmt.Errorf("error resolving contributor: %s %v", str, err)
+					}
+				case columnKindTGN:
+					loc, err := tgn.DefaultClient.Resolve(r.Context(), str)
+					if err != nil {
+						return nil, nil, fmt.Errorf("unknown TGN: %s %v", str, err)
 					}
 
-					str = strings.TrimSpace(str)
-					values = append(values, str)
+					locationJSON, err := json.Marshal(loc)
+					if err != nil {
+						return nil, nil, fmt.Errorf("error marshalling TGN: %s %v", str, err)
+					}
+					str = string(locationJSON)
+					if loc.Coordinates != "" {
+						hierGeoCoords = append(hierGeoCoords, loc.Coordinates)
+					}
+				default:
+					str, err = mapping.transformValue(str)
+					if err != nil {
+						return nil, nil, err
+					}
 				}
 
-				newHeaders[column] = true
-				// replace the locally defined google sheets cell delimiter
-				// with workbench's pipe delimiter
-				row[column] = append(row[column], strings.Join(values, "|"))
-				if len(hierGeoCoords) > 0 {
-					newHeaders["field_coordinates"] = true
-					row["field_coordinates"] = append(row["field_coordinates"], strings.Join(hierGeoCoords, "|"))
-				}
+				str = strings.TrimSpace(str)
+				values = append(values, str)
+			}
+
+			newHeaders[column] = true
+			// replace the locally defined google sheets cell delimiter
+			// with workbench's pipe delimiter
+			row[column] = append(row[column], strings.Join(values, "|"))
+			if len(hierGeoCoords) > 0 {
+				newHeaders["field_coordinates"] = true
+				row["field_coordinates"] = append(row["field_coordinates"], strings.Join(hierGeoCoords, "|"))
 			}
 		}
 
 		rows = append(rows, row)
 	}
+	if resolver.lookupOnly && !resolver.defersCreation() && len(resolver.pending) > 0 {
+		return nil, nil, &unknownTermsError{Terms: resolver.pending, PossibleDuplicates: resolver.duplicates}
+	}
 
 	return newHeaders, rows, nil
 }
 
+// errTermNotCreated is returned when a lookup-only resolver needs a term that
+// doesn't exist yet.
+var errTermNotCreated = errors.New("term does not exist and the caller may not create it")
+
+// pendingTerm is a taxonomy term a transform needs but did not create, either
+// because the caller may not or because it is a dry run.
+type pendingTerm struct {
+	// Placeholder stands in for the term's ID in a dry run's CSV.
+	Placeholder string `json:"placeholder,omitempty"`
+	Vocab       string `json:"vocab"`
+	Name        string `json:"name"`
+	Email       string `json:"email,omitempty"`
+	Orcid       string `json:"orcid,omitempty"`
+	// WorksFor is the institution's term ID or placeholder.
+	WorksFor string `json:"works_for,omitempty"`
+	Ror      string `json:"ror,omitempty"`
+}
+
+// pendingTermsCSVName is the dry run manifest of terms a real run would create.
+const pendingTermsCSVName = "pending-terms.csv"
+
+func writePendingTermsCSV(out io.Writer, terms []pendingTerm) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"placeholder", "vocab", "name", "email", "orcid", "works_for", "ror"}); err != nil {
+		return err
+	}
+	for _, t := range terms {
+		if err := writer.Write([]string{t.Placeholder, t.Vocab, t.Name, t.Email, t.Orcid, t.WorksFor, t.Ror}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// workbenchTermCSVs writes the create_terms input for each vocabulary with
+// pending terms: target.corporate_bodies.csv, which should be run first, and
+// target.agents.csv (see workbench-configs/terms.yml). Terms refer to each
+// other by vocab:name so Workbench can link them once they exist.
+func workbenchTermCSVs(terms []pendingTerm) ([]zipEntry, error) {
+	var bodies, people [][]string
+	for _, t := range terms {
+		switch t.Vocab {
+		case "corporate_body":
+			row := []string{t.Name, ""}
+			if t.Ror != "" {
+				identifier, err := json.Marshal(map[string]string{"attr0": "ror", "value": t.Ror})
+				if err != nil {
+					return nil, err
+				}
+				row[1] = string(identifier)
+			}
+			bodies = append(bodies, row)
+		case "person":
+			row := []string{t.Name, t.Email, "", ""}
+			if t.Orcid != "" {
+				identifier, err := json.Marshal(map[string]string{"attr0": "orcid", "value": t.Orcid})
+				if err != nil {
+					return nil, err
+				}
+				row[2] = string(identifier)
+			}
+			if t.WorksFor != "" {
+				row[3] = "schema:worksFor:" + t.WorksFor
+			}
+			people = append(people, row)
+		}
+	}
+
+	var files []zipEntry
+	for _, f := range []struct {
+		name   string
+		header []string
+		rows   [][]string
+	}{
+		{name: "target.corporate_bodies.csv", header: []string{"term_name", "field_identifier"}, rows: bodies},
+		{name: "target.agents.csv", header: []string{"term_name", "field_email", "field_identifier", "field_relationships"}, rows: people},
+	} {
+		if len(f.rows) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		if err := writer.Write(f.header); err != nil {
+			return nil, err
+		}
+		if err := writer.WriteAll(f.rows); err != nil {
+			return nil, err
+		}
+		files = append(files, zipEntry{name: f.name, body: buf.Bytes()})
+	}
+	return files, nil
+}
+
+// unknownTermsError lists every term a transform would have had to create.
+type unknownTermsError struct {
+	Terms              []pendingTerm       `json:"unknown_contributors"`
+	PossibleDuplicates []possibleDuplicate `json:"possible_duplicates,omitempty"`
+}
+
+func (e *unknownTermsError) Error() string {
+	return fmt.Sprintf("%d contributors do not have taxonomy terms yet", len(e.Terms))
+}
+
 type drupalTermResolver struct {
 	baseURL      string
 	username     string
@@ -362,6 +438,34 @@ type drupalTermResolver struct {
 	client       *http.Client
 	peopleCache  map[string]int
 	institutions map[string]int
+	// cache is shared with every other transform, the maps above only
+	// with this one.
+	cache *termCache
+	// lookups holds every lookup this transform made, so those prefetch
+	// ran in parallel are reused even if cache is off or they expire.
+	lookupsMu sync.Mutex
+	lookups   map[string]termCacheEntry
+	// unshared holds lookups of terms this transform created, which only go
+	// in cache once it succeeds.
+	unshared []termCacheEntry
+	// lookupOnly fails the transform with the terms that would be created
+	// instead, dryRun records them and puts placeholders in the CSV, and
+	// workbenchTerms records them for Workbench to create by name.
+	lookupOnly     bool
+	dryRun         bool
+	workbenchTerms bool
+	pending        []pendingTerm
+	// identity and sheet are recorded with each term in created.
+	identity string
+	sheet    string
+	created  []termAuditEntry
+	// people new to this transform are compared with existing terms of
+	// similar names scoring at least duplicateScore, and any close enough
+	// are listed in duplicates for review.
+	duplicateScore float64
+	peopleSearches map[string][]termCandidate
+	newPeople      []termCandidate
+	duplicates     []possibleDuplicate
 }
 
 func newDrupalTermResolver() *drupalTermResolver {
@@ -379,42 +483,90 @@ func newDrupalTermResolver() *drupalTermResolver {
 	}
 
 	return &drupalTermResolver{
-		baseURL:      strings.TrimRight(baseURL, "/"),
-		username:     username,
-		password:     password,
-		client:       http.DefaultClient,
-		peopleCache:  map[string]int{},
-		institutions: map[string]int{},
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		username:       username,
+		password:       password,
+		client:         http.DefaultClient,
+		peopleCache:    map[string]int{},
+		institutions:   map[string]int{},
+		cache:          sharedTermCache,
+		duplicateScore: duplicateScore,
 	}
 }
 
 func (d *drupalTermResolver) resolveContributor(c contributor.Contributor) (string, error) {
-	parts := strings.Split(c.Name, ":")
-	if len(parts) < 4 {
-		return "", fmt.Errorf("poorly formatted contributor: %s", c.Name)
+	name, err := c.ParseName()
+	if err != nil {
+		return "", err
 	}
+	var tid int
 
-	relator := strings.Join(parts[:2], ":")
-	vocab := parts[2]
-	name := strings.Join(parts[3:], ":")
-	var (
-		tid int
-		err error
-	)
-
-	switch vocab {
+	switch name.Vocab {
 	case "person":
-		tid, err = d.ensurePerson(c, name)
+		c, err = normalizeContributor(c)
+		if err != nil {
+			return "", err
+		}
+		tid, err = d.ensurePerson(c, name.Display)
 	case "corporate_body":
-		tid, err = d.ensureInstitution(name)
+		tid, err = d.ensureInstitution(name.Display, "")
 	default:
-		return c.Name, nil
+		return name.String(), nil
 	}
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("%s:%d", relator, tid), nil
+	return fmt.Sprintf("%s:%s", name.Relator, d.termRef(tid)), nil
+}
+
+// normalizeContributor puts a person's ORCiD and their institution's ROR ID
+// in their canonical forms, so they are looked up and stored one way, and
+// rejects either if it isn't valid before it can become authority data.
+func normalizeContributor(c contributor.Contributor) (contributor.Contributor, error) {
+	if c.Orcid != "" {
+		orcid, err := contributor.NormalizeOrcid(c.Orcid)
+		if err != nil {
+			return c, err
+		}
+		c.Orcid = orcid
+	}
+	if c.InstitutionRor != "" {
+		if c.Institution == "" {
+			return c, fmt.Errorf("institution_ror %s needs the institution's name", c.InstitutionRor)
+		}
+		ror, err := contributor.NormalizeRor(c.InstitutionRor)
+		if err != nil {
+			return c, err
+		}
+		c.InstitutionRor = ror
+	}
+	return c, nil
+}
+
+// defersCreation reports whether terms are recorded in pending rather than
+// created.
+func (d *drupalTermResolver) defersCreation() bool {
+	return d.dryRun || d.workbenchTerms
+}
+
+// createsTerms reports whether missing terms end up created, by this
+// transform or by Workbench.
+func (d *drupalTermResolver) createsTerms() bool {
+	return !d.lookupOnly && !d.dryRun
+}
+
+// termRef formats a term ID. Pending terms have negative IDs and are referred
+// to by vocab:name when Workbench will create them, or a dry run placeholder.
+func (d *drupalTermResolver) termRef(tid int) string {
+	if tid >= 0 {
+		return strconv.Itoa(tid)
+	}
+	if d.workbenchTerms {
+		term := d.pending[-tid-1]
+		return term.Vocab + ":" + term.Name
+	}
+	return fmt.Sprintf("pending-%d", -tid)
 }
 
 func (d *drupalTermResolver) ensurePerson(c contributor.Contributor, name string) (int, error) {
@@ -423,50 +575,58 @@ func (d *drupalTermResolver) ensurePerson(c contributor.Contributor, name string
 		c.Email,
 		c.Orcid,
 		c.Institution,
+		c.InstitutionRor,
 	}, "|")))
 	if tid, ok := d.peopleCache[cacheKey]; ok {
 		return tid, nil
 	}
 
-	lookupParams := url.Values{}
-	lookupParams.Set("name", name)
-	lookupParams.Set("vocab", "person")
-
-	uniqueLookup := false
+	uniqueLookup := c.Email != "" || c.Orcid != ""
 	var institutionID int
-	if c.Email != "" {
-		lookupParams.Set("email", c.Email)
-		uniqueLookup = true
-	} else if c.Orcid != "" {
-		lookupParams.Set("orcid", c.Orcid)
-		uniqueLookup = true
-	} else if c.Institution != "" {
+	if !uniqueLookup && c.Institution != "" {
 		var err error
-		institutionID, err = d.ensureInstitution(c.Institution)
+		institutionID, err = d.ensureInstitution(c.Institution, c.InstitutionRor)
+		if errors.Is(err, errTermNotCreated) {
+			return 0, d.unknownPerson(c, name)
+		}
 		if err != nil {
 			return 0, err
 		}
-		lookupParams.Set("works_for", strconv.Itoa(institutionID))
 	}
+	lookupParams := personLookupParams(c, name, institutionID)
 
-	tid, foundName, found, err := d.lookupTerm(lookupParams)
-	if err != nil {
-		return 0, err
+	var (
+		tid       int
+		foundName string
+		found     bool
+		err       error
+	)
+	// nobody can work for an institution a dry run has yet to create
+	if institutionID >= 0 {
+		tid, foundName, found, err = d.lookupTerm(lookupParams)
+		if err != nil {
+			return 0, err
+		}
 	}
 	if found {
 		// If we matched by a unique identifier but the stored name differs, create a
 		// new child term and keep lineage via parent.
 		if uniqueLookup && strings.TrimSpace(foundName) != "" && strings.TrimSpace(foundName) != strings.TrimSpace(name) {
 			if institutionID == 0 && c.Institution != "" {
-				institutionID, err = d.ensureInstitution(c.Institution)
+				institutionID, err = d.ensureInstitution(c.Institution, c.InstitutionRor)
+				if errors.Is(err, errTermNotCreated) {
+					return 0, d.unknownPerson(c, name)
+				}
 				if err != nil {
 					return 0, err
 				}
 			}
-			childID, err := d.createTerm("person", name, c.Email, c.Orcid, institutionID)
+			childID, err := d.createTerm("person", name, c.Email, c.Orcid, "", institutionID, termReasonNameMismatch)
 			if err != nil {
 				return 0, err
 			}
+			d.newPeople = append(d.newPeople, termCandidate{tid: childID, name: name})
+			d.rememberTerm(lookupParams, childID, name)
 			d.peopleCache[cacheKey] = childID
 			return childID, nil
 		}
@@ -475,48 +635,162 @@ func (d *drupalTermResolver) ensurePerson(c contributor.Contributor, name string
 	}
 
 	if institutionID == 0 && c.Institution != "" {
-		institutionID, err = d.ensureInstitution(c.Institution)
+		institutionID, err = d.ensureInstitution(c.Institution, c.InstitutionRor)
+		if errors.Is(err, errTermNotCreated) {
+			return 0, d.unknownPerson(c, name)
+		}
 		if err != nil {
 			return 0, err
 		}
 	}
 
-	tid, err = d.createTerm("person", name, c.Email, c.Orcid, institutionID)
+	candidates := d.duplicateCandidates(name)
+	// a name that only differs from one term's in order, punctuation or
+	// diacritics is that term, unless an identifier or employer was given
+	if !uniqueLookup && c.Institution == "" && len(candidates) > 0 && candidates[0].Score == 1 &&
+		(len(candidates) == 1 || candidates[1].Score < 1) {
+		// still listed, so catalogers can check the link
+		d.reportDuplicates(c, name, candidates[0].tid, candidates)
+		d.peopleCache[cacheKey] = candidates[0].tid
+		return candidates[0].tid, nil
+	}
+
+	tid, err = d.createTerm("person", name, c.Email, c.Orcid, "", institutionID, termReasonNotFound)
+	if err != nil && !errors.Is(err, errTermNotCreated) {
+		return 0, err
+	}
+	d.reportDuplicates(c, name, tid, candidates)
 	if err != nil {
 		return 0, err
 	}
+	d.newPeople = append(d.newPeople, termCandidate{tid: tid, name: name})
+	d.rememberTerm(lookupParams, tid, name)
 	d.peopleCache[cacheKey] = tid
 	return tid, nil
 }
 
-func (d *drupalTermResolver) ensureInstitution(name string) (int, error) {
-	cacheKey := strings.ToLower(strings.TrimSpace(name))
-	if tid, ok := d.institutions[cacheKey]; ok {
+// unknownPerson reports a person a lookup only transform can't resolve because
+// their institution is unknown too, working for that institution by name.
+func (d *drupalTermResolver) unknownPerson(c contributor.Contributor, name string) error {
+	d.reportDuplicates(c, name, 0, d.duplicateCandidates(name))
+	return d.reportUnknown(pendingTerm{
+		Vocab:    "person",
+		Name:     name,
+		Email:    c.Email,
+		Orcid:    c.Orcid,
+		WorksFor: "corporate_body:" + c.Institution,
+	})
+}
+
+func (d *drupalTermResolver) ensureInstitution(name, ror string) (int, error) {
+	// an institution is known by its name and, when given, its ROR ID, so a
+	// later row naming it either way finds the same term
+	keys := []string{strings.ToLower(strings.TrimSpace(name))}
+	if ror != "" {
+		keys = []string{"ror:" + ror, keys[0]}
+	}
+	if tid, ok := d.institutions[keys[0]]; ok {
 		return tid, nil
 	}
 
-	params := url.Values{}
-	params.Set("name", name)
-	params.Set("vocab", "corporate_body")
+	lookups := institutionLookups(name, ror)
+	for _, params := range lookups {
+		tid, _, found, err := d.lookupTerm(params)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			d.rememberInstitution(keys, tid)
+			return tid, nil
+		}
+	}
 
-	tid, _, found, err := d.lookupTerm(params)
+	tid, err := d.createTerm("corporate_body", name, "", "", ror, 0, termReasonNotFound)
 	if err != nil {
 		return 0, err
 	}
-	if found {
-		d.institutions[cacheKey] = tid
-		return tid, nil
+	for _, params := range lookups {
+		d.rememberTerm(params, tid, name)
 	}
+	d.rememberInstitution(keys, tid)
+	return tid, nil
+}
 
-	tid, err = d.createTerm("corporate_body", name, "", "", 0)
-	if err != nil {
-		return 0, err
+func (d *drupalTermResolver) rememberInstitution(keys []string, tid int) {
+	for _, key := range keys {
+		if _, ok := d.institutions[key]; !ok {
+			d.institutions[key] = tid
+		}
 	}
-	d.institutions[cacheKey] = tid
-	return tid, nil
+}
+
+// personLookupParams finds a person by email, else ORCiD, else the
+// institution they work for, along with their name.
+func personLookupParams(c contributor.Contributor, name string, institutionID int) url.Values {
+	params := url.Values{}
+	params.Set("name", name)
+	params.Set("vocab", "person")
+	switch {
+	case c.Email != "":
+		params.Set("email", c.Email)
+	case c.Orcid != "":
+		params.Set("orcid", c.Orcid)
+	case institutionID != 0:
+		params.Set("works_for", strconv.Itoa(institutionID))
+	}
+	return params
+}
+
+// institutionLookups finds an institution by its ROR ID, whatever it is
+// called, and then by name for terms that don't have one yet.
+func institutionLookups(name, ror string) []url.Values {
+	if ror == "" {
+		return []url.Values{institutionLookupParams(name)}
+	}
+	params := url.Values{}
+	params.Set("vocab", "corporate_body")
+	params.Set("ror", ror)
+	return []url.Values{params, institutionLookupParams(name)}
+}
+
+// createdTermLookups returns every lookup that would find a term created
+// with these fields.
+func createdTermLookups(vocab, name, email, orcid, ror string, institutionID int) []url.Values {
+	if vocab == "corporate_body" {
+		return institutionLookups(name, ror)
+	}
+	lookups := []url.Values{personLookupParams(contributor.Contributor{}, name, 0)}
+	if email != "" {
+		lookups = append(lookups, personLookupParams(contributor.Contributor{Email: email}, name, 0))
+	}
+	if orcid != "" {
+		lookups = append(lookups, personLookupParams(contributor.Contributor{Orcid: orcid}, name, 0))
+	}
+	if institutionID > 0 {
+		lookups = append(lookups, personLookupParams(contributor.Contributor{}, name, institutionID))
+	}
+	return lookups
+}
+
+func institutionLookupParams(name string) url.Values {
+	params := url.Values{}
+	params.Set("name", name)
+	params.Set("vocab", "corporate_body")
+	return params
 }
 
 func (d *drupalTermResolver) lookupTerm(params url.Values) (int, string, bool, error) {
+	key := termCacheKey(d.baseURL, params)
+	if entry, ok := d.recall(key); ok {
+		return entry.tid, entry.name, entry.found, nil
+	}
+	// a term may have been created in Drupal since another transform missed
+	// it, so only resolvers that won't create it trust a shared miss
+	if entry, ok := d.cache.get(key); ok && (entry.found || !d.createsTerms()) {
+		d.memorize(entry)
+		return entry.tid, entry.name, entry.found, nil
+	}
+
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/term_from_term_name?%s", d.baseURL, params.Encode()), nil)
 	if err != nil {
 		return 0, "", false, err
@@ -544,6 +818,8 @@ func (d *drupalTermResolver) lookupTerm(params url.Values) (int, string, bool, e
 		return 0, "", false, err
 	}
 	if !found {
+		d.cache.set(key, 0, "", false)
+		d.memorize(termCacheEntry{key: key})
 		return 0, "", false, nil
 	}
 	tid, _, err := termIDFromResponse(term)
@@ -554,10 +830,88 @@ func (d *drupalTermResolver) lookupTerm(params url.Values) (int, string, bool, e
 	if len(term.Name) > 0 {
 		foundName = term.Name[0].Value
 	}
+	entry := termCacheEntry{key: key, tid: tid, name: foundName, found: true}
+	d.share(entry)
+	d.memorize(entry)
 	return tid, foundName, true, nil
 }
 
-func (d *drupalTermResolver) createTerm(vocab, name, email, orcid string, institutionID int) (int, error) {
+// rememberTerm remembers a term created after a lookup with params came up
+// empty (or with another name), so later rows find it without asking Drupal,
+// as does the next transform once this one succeeds. Dry run placeholders are
+// never remembered.
+func (d *drupalTermResolver) rememberTerm(params url.Values, tid int, name string) {
+	if tid > 0 {
+		entry := termCacheEntry{key: termCacheKey(d.baseURL, params), tid: tid, name: name, found: true}
+		d.share(entry)
+		d.memorize(entry)
+	}
+}
+
+// share caches a lookup for every transform, unless it found a term this
+// transform created. Those wait for shareCreated, so a rollback can't leave
+// another transform using a term it deleted.
+func (d *drupalTermResolver) share(entry termCacheEntry) {
+	for _, created := range d.created {
+		if created.Tid == entry.tid {
+			d.lookupsMu.Lock()
+			d.unshared = append(d.unshared, entry)
+			d.lookupsMu.Unlock()
+			return
+		}
+	}
+	d.cache.set(entry.key, entry.tid, entry.name, entry.found)
+}
+
+// shareCreated caches the lookups of the terms this transform created, once
+// it has succeeded.
+func (d *drupalTermResolver) shareCreated() {
+	d.lookupsMu.Lock()
+	defer d.lookupsMu.Unlock()
+	for _, entry := range d.unshared {
+		d.cache.set(entry.key, entry.tid, entry.name, entry.found)
+	}
+	d.unshared = nil
+}
+
+// reportUnknown records a term a lookup only transform would have to create,
+// once, and returns errTermNotCreated.
+func (d *drupalTermResolver) reportUnknown(term pendingTerm) error {
+	for _, p := range d.pending {
+		if p == term {
+			return errTermNotCreated
+		}
+	}
+	d.pending = append(d.pending, term)
+	return errTermNotCreated
+}
+
+// createTerm creates a taxonomy term and records it in the audit log, noting
+// reason it had to be created.
+func (d *drupalTermResolver) createTerm(vocab, name, email, orcid, ror string, institutionID int, reason string) (int, error) {
+	if d.defersCreation() || d.lookupOnly {
+		term := pendingTerm{Vocab: vocab, Name: name, Email: email, Orcid: orcid, Ror: ror}
+		if institutionID != 0 {
+			term.WorksFor = d.termRef(institutionID)
+		}
+		if !d.defersCreation() {
+			return 0, d.reportUnknown(term)
+		}
+		// Workbench creates terms by name, so one with the same name is the
+		// same term to it
+		for i, p := range d.pending {
+			p.Placeholder = ""
+			if p == term || (d.workbenchTerms && p.Vocab == term.Vocab && p.Name == term.Name) {
+				return -(i + 1), nil
+			}
+		}
+		// pending terms get negative IDs for termRef to render, and are listed
+		// under the same reference the node CSV uses
+		tid := -(len(d.pending) + 1)
+		d.pending = append(d.pending, term)
+		d.pending[-tid-1].Placeholder = d.termRef(tid)
+		return tid, nil
+	}
 	if d.password == "" {
 		return 0, fmt.Errorf("unable to create term %q because FABRICATOR_DRUPAL_PASSWORD or ISLANDORA_WORKBENCH_PASSWORD is not set", name)
 	}
@@ -571,6 +925,9 @@ func (d *drupalTermResolver) createTerm(vocab, name, email, orcid string, instit
 	if orcid != "" {
 		body["field_identifier"] = []map[string]string{{"attr0": "orcid", "value": orcid}}
 	}
+	if ror != "" {
+		body["field_identifier"] = []map[string]string{{"attr0": "ror", "value": ror}}
+	}
 	if institutionID > 0 {
 		body["field_relationships"] = []map[string]interface{}{{
 			"target_id": institutionID,
@@ -600,6 +957,8 @@ func (d *drupalTermResolver) createTerm(vocab, name, email, orcid string, instit
 		raw, _ := io.ReadAll(resp.Body)
 		return 0, fmt.Errorf("term create failed with status %d: %s", resp.StatusCode, string(raw))
 	}
+	// the new term may be what earlier lookups were missing
+	d.forgetMisses(createdTermLookups(vocab, name, email, orcid, ror, institutionID))
 
 	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -612,9 +971,75 @@ func (d *drupalTermResolver) createTerm(vocab, name, email, orcid string, instit
 	if !found {
 		return 0, fmt.Errorf("term create response did not include a term id")
 	}
+
+	entry := termAuditEntry{
+		Time:     time.Now().UTC(),
+		Identity: d.identity,
+		Sheet:    d.sheet,
+		Vocab:    vocab,
+		Name:     name,
+		Email:    email,
+		Orcid:    orcid,
+		Ror:      ror,
+		Tid:      tid,
+		Reason:   reason,
+	}
+	d.created = append(d.created, entry)
+	// the term exists now, so a failed audit write shouldn't fail the transform
+	if err := activeAuditLog.record(entry); err != nil {
+		slog.Error("Failed to write term to audit log", "tid", tid, "err", err)
+	}
 	return tid, nil
 }
 
+// rollback deletes the terms created so far, newest first so people go before
+// the institutions they work for, and returns any it could not delete. The
+// terms were never cached for other transforms, but one that looked a term up
+// in Drupal between its creation and deletion still refers to it.
+func (d *drupalTermResolver) rollback() []termAuditEntry {
+	var orphans []termAuditEntry
+	for i := len(d.created) - 1; i >= 0; i-- {
+		entry := d.created[i]
+		if err := d.deleteTerm(entry.Tid); err != nil {
+			slog.Error("Failed to roll back term", "tid", entry.Tid, "name", entry.Name, "err", err)
+			orphans = append(orphans, entry)
+			continue
+		}
+		d.cache.forgetTerm(entry.Tid)
+		d.forgetLookups(entry.Tid)
+		entry.Time = time.Now().UTC()
+		entry.Reason = termReasonRolledBack
+		if err := activeAuditLog.record(entry); err != nil {
+			slog.Error("Failed to write term to audit log", "tid", entry.Tid, "err", err)
+		}
+	}
+	d.created = nil
+	return orphans
+}
+
+func (d *drupalTermResolver) deleteTerm(tid int) error {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/taxonomy/term/%d?_format=json", d.baseURL, tid), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(d.username, d.password)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// already gone is as good as deleted
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("term delete failed with status %d: %s", resp.StatusCode, string(raw))
+	}
+	return nil
+}
+
 type drupalTermResponse struct {
 	Tid []struct {
 		Value json.Number `json:"value"`
@@ -668,6 +1093,7 @@ func ResolvePersonTermID(name, institution, orcid, email string) (int, error) {
 	}
 
 	resolver := newDrupalTermResolver()
+	resolver.identity = "cli"
 	c := contributor.Contributor{
 		Name:        fmt.Sprintf("relators:aut:person:%s", strings.TrimSpace(name)),
 		Institution: strings.TrimSpace(institution),
@@ -678,6 +1104,7 @@ func ResolvePersonTermID(name, institution, orcid, email string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	resolver.shareCreated()
 
 	parts := strings.Split(resolved, ":")
 	if len(parts) < 3 {
@@ -3,14 +3,20 @@ package handlers
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/lehigh-university-libraries/fabricator/internal/contributor"
 )
@@ -140,7 +146,7 @@ In Copyright,foo,bar,Full Test Title`,
 			req.Header.Set("Content-Type", "text/csv")
 
 			// Call function under test
-			headers, rows, err := readCSVWithJSONTags(req)
+			headers, rows, err := readCSVWithJSONTags(req, newDrupalTermResolver())
 			firstRow := make([]string, 0, len(headers))
 			for header := range headers {
 				firstRow = append(firstRow, header)
@@ -232,7 +238,7 @@ func TestNormalizedWorkbenchHeaders(t *testing.T) {
 	}
 }
 
-func TestTargetCSVPath(t *testing.T) {
+func TestTargetCSVName(t *testing.T) {
 	tests := []struct {
 		name     string
 		headers  map[string]bool
@@ -243,14 +249,14 @@ func TestTargetCSVPath(t *testing.T) {
 			headers: map[string]bool{
 				"title": true,
 			},
-			expected: "/tmp/target.csv",
+			expected: "target.csv",
 		},
 		{
 			name: "update csv",
 			headers: map[string]bool{
 				"node_id": true,
 			},
-			expected: "/tmp/target.update.csv",
+			expected: "target.update.csv",
 		},
 		{
 			name: "add media csv",
@@ -258,7 +264,7 @@ func TestTargetCSVPath(t *testing.T) {
 				"node_id": true,
 				"file":    true,
 			},
-			expected: "/tmp/target.add_media.csv",
+			expected: "target.add_media.csv",
 		},
 		{
 			name: "update ignores upload and parent ids plus file path",
@@ -270,13 +276,13 @@ func TestTargetCSVPath(t *testing.T) {
 				"field_weight": true,
 				"field_note":   true,
 			},
-			expected: "/tmp/target.update.csv",
+			expected: "target.update.csv",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := targetCSVPath(tt.headers)
+			got := targetCSVName(tt.headers)
 			if got != tt.expected {
 				t.Fatalf("expected %s, got %s", tt.expected, got)
 			}
@@ -360,6 +366,58 @@ func TestTransformCsvAddMediaTargetName(t *testing.T) {
 	}
 }
 
+func TestTransformCsvConcurrentRequestsAreIsolated(t *testing.T) {
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			title := fmt.Sprintf("Title %d", n)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("Title,Object Model,Full Title\n"+title+",Image,"+title+"\n"))
+			req.Header.Set("Content-Type", "text/csv")
+			rec := httptest.NewRecorder()
+
+			TransformCsv(rec, req)
+
+			if rec.Code != http.StatusOK {
+				errs <- fmt.Errorf("worker %d: expected status 200, got %d", n, rec.Code)
+				return
+			}
+			body := rec.Body.Bytes()
+			reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			if err != nil {
+				errs <- fmt.Errorf("worker %d: failed to read zip: %v", n, err)
+				return
+			}
+			if len(reader.File) != 1 || reader.File[0].Name != "target.csv" {
+				errs <- fmt.Errorf("worker %d: unexpected zip contents", n)
+				return
+			}
+			file, err := reader.File[0].Open()
+			if err != nil {
+				errs <- fmt.Errorf("worker %d: failed to open zipped csv: %v", n, err)
+				return
+			}
+			defer file.Close()
+			csvBody, err := io.ReadAll(file)
+			if err != nil {
+				errs <- fmt.Errorf("worker %d: failed to read zipped csv: %v", n, err)
+				return
+			}
+			if got := strings.Count(string(csvBody), "Title "); got != 2 || !strings.Contains(string(csvBody), title) {
+				errs <- fmt.Errorf("worker %d: expected only its own row, got %q", n, csvBody)
+			}
+		}(n)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
 func equalHeaderMaps(a, b map[string]bool) bool {
 	if len(a) != len(b) {
 		return false
@@ -443,7 +501,7 @@ func TestResolveContributorEmailLookupPrecedence(t *testing.T) {
 	got, err := resolver.resolveContributor(contributor.Contributor{
 		Name:        "relators:cre:person:Smith, Sam",
 		Email:       "person@example.edu",
-		Orcid:       "0000-0000-0000-0000",
+		Orcid:       "0000-0002-1825-0097",
 		Institution: "Lehigh University",
 	})
 	if err != nil {
@@ -553,7 +611,7 @@ func TestResolvePersonTermID(t *testing.T) {
 		_ = os.Setenv("FABRICATOR_TERM_LOOKUP_URL", original)
 	}()
 
-	tid, err := ResolvePersonTermID("Smith, Sam", "Lehigh University", "0000-0000-0000-0000", "person@example.edu")
+	tid, err := ResolvePersonTermID("Smith, Sam", "Lehigh University", "0000-0002-1825-0097", "person@example.edu")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -613,6 +671,270 @@ func TestResolveContributorUniqueIDNameMismatchCreatesChild(t *testing.T) {
 	}
 }
 
+func TestResolveContributorNormalizesOrcid(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			if got := r.URL.Query().Get("orcid"); got != "0000-0002-1694-233X" {
+				t.Fatalf("expected a lookup by the bare iD, got %q", got)
+			}
+			_, _ = w.Write([]byte(`[]`))
+		case "/taxonomy/term":
+			var payload struct {
+				Identifier []map[string]string `json:"field_identifier"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Fatalf("failed decoding payload: %v", err)
+			}
+			if len(payload.Identifier) != 1 || payload.Identifier[0]["value"] != "0000-0002-1694-233X" {
+				t.Fatalf("expected the bare iD to be stored, got %v", payload.Identifier)
+			}
+			_, _ = w.Write([]byte(`{"tid":[{"value":88}]}`))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	resolver := &drupalTermResolver{
+		baseURL:      ts.URL,
+		username:     "workbench",
+		password:     "secret",
+		client:       ts.Client(),
+		peopleCache:  map[string]int{},
+		institutions: map[string]int{},
+	}
+	got, err := resolver.resolveContributor(contributor.Contributor{
+		Name:  "relators:cre:person:Carberry, Josiah",
+		Orcid: "https://orcid.org/0000-0002-1694-233x",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "relators:cre:88" {
+		t.Fatalf("unexpected resolved contributor: %s", got)
+	}
+
+	requests = 0
+	_, err = resolver.resolveContributor(contributor.Contributor{
+		Name:  "relators:cre:person:Carberry, Josiah",
+		Orcid: "0000-0002-1694-2330",
+	})
+	if !errors.Is(err, contributor.ErrOrcidChecksum) {
+		t.Fatalf("expected a bad check digit to be rejected, got %v", err)
+	}
+	if requests != 0 {
+		t.Fatalf("expected an invalid ORCiD to never reach Drupal, got %d requests", requests)
+	}
+}
+
+func TestResolveContributorInstitutionRor(t *testing.T) {
+	tests := []struct {
+		name       string
+		byRor      bool
+		byName     bool
+		lookups    []string
+		identifier string
+		want       string
+	}{
+		{
+			name:    "found by ROR ID whatever it is called",
+			byRor:   true,
+			byName:  true,
+			lookups: []string{"ror=012afjb06&vocab=corporate_body", "name=Sam+Smith&vocab=person&works_for=62"},
+			want:    "relators:cre:500",
+		},
+		{
+			name:    "falls back to the name",
+			byName:  true,
+			lookups: []string{"ror=012afjb06&vocab=corporate_body", "name=Lehigh+Univ.&vocab=corporate_body", "name=Sam+Smith&vocab=person&works_for=62"},
+			want:    "relators:cre:500",
+		},
+		{
+			name:       "created with the ROR ID",
+			lookups:    []string{"ror=012afjb06&vocab=corporate_body", "name=Lehigh+Univ.&vocab=corporate_body", "name=Sam+Smith&vocab=person&works_for=63"},
+			identifier: "012afjb06",
+			want:       "relators:cre:500",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookups []string
+			var identifier string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/term_from_term_name":
+					q := r.URL.Query()
+					lookups = append(lookups, q.Encode())
+					switch {
+					case q.Get("vocab") == "person":
+						_, _ = w.Write([]byte(`[{"tid":[{"value":500}]}]`))
+					case q.Get("ror") != "" && tt.byRor, q.Get("name") != "" && tt.byName:
+						_, _ = w.Write([]byte(`[{"tid":[{"value":62}]}]`))
+					default:
+						_, _ = w.Write([]byte(`[]`))
+					}
+				case "/taxonomy/term":
+					var payload struct {
+						Identifier []map[string]string `json:"field_identifier"`
+					}
+					if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+						t.Fatalf("failed decoding payload: %v", err)
+					}
+					if len(payload.Identifier) == 1 && payload.Identifier[0]["attr0"] == "ror" {
+						identifier = payload.Identifier[0]["value"]
+					}
+					_, _ = w.Write([]byte(`{"tid":[{"value":63}]}`))
+				default:
+					t.Fatalf("unexpected path: %s", r.URL.Path)
+				}
+			}))
+			defer ts.Close()
+
+			resolver := &drupalTermResolver{
+				baseURL:      ts.URL,
+				username:     "workbench",
+				password:     "secret",
+				client:       ts.Client(),
+				peopleCache:  map[string]int{},
+				institutions: map[string]int{},
+			}
+			got, err := resolver.resolveContributor(contributor.Contributor{
+				Name:           "relators:cre:person:Sam Smith",
+				Institution:    "Lehigh Univ.",
+				InstitutionRor: "https://ror.org/012afjb06",
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("unexpected resolved contributor: %s", got)
+			}
+			if fmt.Sprint(lookups) != fmt.Sprint(tt.lookups) {
+				t.Fatalf("expected lookups %v, got %v", tt.lookups, lookups)
+			}
+			if identifier != tt.identifier {
+				t.Fatalf("expected the institution created with ROR ID %q, got %q", tt.identifier, identifier)
+			}
+		})
+	}
+}
+
+// An institution created with its ROR ID is the same one a later row names
+// without it, and the other way around.
+func TestTransformCsvCreatesInstitutionOnce(t *testing.T) {
+	csvContents := map[string]string{
+		"ROR ID first": `Title,Contributor
+One,"{""name"":""relators:cre:person:Sam Smith"",""institution"":""Lehigh University"",""institution_ror"":""012afjb06""}"
+Two,"{""name"":""relators:cre:person:Ann Jones"",""institution"":""Lehigh University""}"
+`,
+		"name first": `Title,Contributor
+One,"{""name"":""relators:cre:person:Ann Jones"",""institution"":""Lehigh University""}"
+Two,"{""name"":""relators:cre:person:Sam Smith"",""institution"":""Lehigh University"",""institution_ror"":""012afjb06""}"
+`,
+	}
+	for name, csvContent := range csvContents {
+		t.Run(name, func(t *testing.T) {
+			sharedTermCache.flush()
+			var institutions, people int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/term_from_term_name":
+					_, _ = w.Write([]byte(`[]`))
+				case "/jsonapi/taxonomy_term/person":
+					_, _ = w.Write([]byte(`{"data":[]}`))
+				case "/taxonomy/term":
+					var payload struct {
+						Vid []map[string]string `json:"vid"`
+					}
+					if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+						t.Fatalf("failed decoding payload: %v", err)
+					}
+					if len(payload.Vid) == 1 && payload.Vid[0]["target_id"] == "corporate_body" {
+						institutions++
+						_, _ = w.Write([]byte(`{"tid":[{"value":62}]}`))
+						return
+					}
+					people++
+					_, _ = w.Write([]byte(fmt.Sprintf(`{"tid":[{"value":%d}]}`, 100+people)))
+				default:
+					t.Fatalf("unexpected path: %s", r.URL.Path)
+				}
+			}))
+			defer ts.Close()
+			t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+			t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(csvContent))
+			req.Header.Set("Content-Type", "text/csv")
+			req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: RoleIngester}))
+			rec := httptest.NewRecorder()
+
+			TransformCsv(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if institutions != 1 || people != 2 {
+				t.Fatalf("expected 1 institution and 2 people created, got %d and %d", institutions, people)
+			}
+		})
+	}
+}
+
+func TestResolveContributorNameKeys(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/term_from_term_name" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if q := r.URL.Query(); q.Get("vocab") != "person" || q.Get("name") != "Smith: Sam" {
+			t.Fatalf("expected the whole name to be looked up, got query: %s", q.Encode())
+		}
+		_, _ = w.Write([]byte(`[{"tid":[{"value":44}]}]`))
+	}))
+	defer ts.Close()
+
+	resolver := &drupalTermResolver{
+		baseURL:      ts.URL,
+		client:       ts.Client(),
+		peopleCache:  map[string]int{},
+		institutions: map[string]int{},
+	}
+	tests := []struct {
+		contributor contributor.Contributor
+		want        string
+	}{
+		{
+			contributor: contributor.Contributor{Relator: "ths", Vocab: "person", Name: "Smith: Sam"},
+			want:        "relators:ths:44",
+		},
+		{
+			contributor: contributor.Contributor{Name: "relators:aut:person:Smith: Sam"},
+			want:        "relators:aut:44",
+		},
+		{
+			// vocabularies without terms to look up pass through packed
+			contributor: contributor.Contributor{Relator: "relators:aut", Vocab: "family", Name: "Smith: Family"},
+			want:        "relators:aut:family:Smith: Family",
+		},
+	}
+	for _, tt := range tests {
+		got, err := resolver.resolveContributor(tt.contributor)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, got)
+		}
+	}
+
+	if _, err := resolver.resolveContributor(contributor.Contributor{Vocab: "person", Name: "Smith"}); !errors.Is(err, contributor.ErrPartialName) {
+		t.Fatalf("expected a vocab without a relator to be rejected, got %v", err)
+	}
+}
+
 func TestReadCSVWithContributorMapsToFieldLinkedAgent(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/term_from_term_name" {
@@ -639,7 +961,7 @@ func TestReadCSVWithContributorMapsToFieldLinkedAgent(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(csvContent))
 	req.Header.Set("Content-Type", "text/csv")
 
-	headers, rows, err := readCSVWithJSONTags(req)
+	headers, rows, err := readCSVWithJSONTags(req, newDrupalTermResolver())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -654,3 +976,528 @@ func TestReadCSVWithContributorMapsToFieldLinkedAgent(t *testing.T) {
 		t.Fatalf("unexpected field_linked_agent value: %#v", got)
 	}
 }
+
+func TestTransformCsvOnlyIngestersCreateTerms(t *testing.T) {
+	var creates int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			if r.URL.Query().Get("name") == "Known, Kim" {
+				_, _ = w.Write([]byte(`[{"tid":[{"value":10}]}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[]`))
+		case "/taxonomy/term":
+			creates++
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"tid":[{"value":%d}]}`, 100+creates)))
+		case "/jsonapi/taxonomy_term/person":
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+	csvContent := `Title,Contributor
+One,"{""name"":""relators:cre:person:Known, Kim""} ; {""name"":""relators:aut:person:New, Nia"",""email"":""nia@lehigh.edu""}"
+Two,"{""name"":""relators:aut:person:New, Nia"",""email"":""nia@lehigh.edu""} ; {""name"":""relators:pbl:corporate_body:New Press""}"
+Three,"{""name"":""relators:ths:person:Advisor, Ada"",""institution"":""New College""}"
+`
+	tests := []struct {
+		name       string
+		role       Role
+		statusCode int
+		creates    int
+		unknown    []pendingTerm
+	}{
+		{
+			name:       "editor",
+			role:       RoleEditor,
+			statusCode: http.StatusUnprocessableEntity,
+			// a person is reported along with the institution they work for
+			unknown: []pendingTerm{
+				{Vocab: "person", Name: "New, Nia", Email: "nia@lehigh.edu"},
+				{Vocab: "corporate_body", Name: "New Press"},
+				{Vocab: "corporate_body", Name: "New College"},
+				{Vocab: "person", Name: "Advisor, Ada", WorksFor: "corporate_body:New College"},
+			},
+		},
+		{name: "ingester", role: RoleIngester, statusCode: http.StatusOK, creates: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creates = 0
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(csvContent))
+			req.Header.Set("Content-Type", "text/csv")
+			req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: tt.role}))
+			rec := httptest.NewRecorder()
+
+			TransformCsv(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+			if creates != tt.creates {
+				t.Fatalf("expected %d terms created, got %d", tt.creates, creates)
+			}
+			if tt.unknown == nil {
+				return
+			}
+			var got unknownTermsError
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("failed decoding response: %v", err)
+			}
+			if fmt.Sprint(got.Terms) != fmt.Sprint(tt.unknown) {
+				t.Fatalf("expected unknown contributors %v, got %v", tt.unknown, got.Terms)
+			}
+		})
+	}
+}
+
+// readZipFiles returns the contents of each file in a transform response.
+func readZipFiles(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("failed to read zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		raw, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(raw)
+	}
+	return files
+}
+
+func TestTransformCsvDryRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			q := r.URL.Query()
+			if strings.HasPrefix(q.Get("works_for"), "-") {
+				t.Fatalf("looked up a person working for a pending institution: %s", q.Encode())
+			}
+			if q.Get("name") == "Known, Kim" {
+				_, _ = w.Write([]byte(`[{"tid":[{"value":10}]}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[]`))
+		case "/jsonapi/taxonomy_term/person":
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		default:
+			t.Fatalf("dry run made a request to %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+	csvContent := `Title,Contributor
+One,"{""name"":""relators:cre:person:Known, Kim""} ; {""name"":""relators:aut:person:New, Nia"",""institution"":""New College""}"
+Two,"{""name"":""relators:aut:person:New, Nia"",""institution"":""New College""} ; {""name"":""relators:pbl:corporate_body:New College""}"
+`
+	tests := []struct {
+		name string
+		role Role
+	}{
+		{name: "ingester", role: RoleIngester},
+		{name: "editor", role: RoleEditor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/?dry_run=true", strings.NewReader(csvContent))
+			req.Header.Set("Content-Type", "text/csv")
+			req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: tt.role}))
+			rec := httptest.NewRecorder()
+
+			TransformCsv(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			files := readZipFiles(t, rec.Body.Bytes())
+			target := files["target.csv"]
+			for _, want := range []string{"relators:cre:10|relators:aut:pending-2", "relators:aut:pending-2|relators:pbl:pending-1"} {
+				if !strings.Contains(target, want) {
+					t.Errorf("expected %q in target.csv, got %s", want, target)
+				}
+			}
+			expected := "placeholder,vocab,name,email,orcid,works_for,ror\n" +
+				"pending-1,corporate_body,New College,,,,\n" +
+				"pending-2,person,\"New, Nia\",,,pending-1,\n"
+			if got := files[pendingTermsCSVName]; got != expected {
+				t.Errorf("expected manifest %q, got %q", expected, got)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/?dry_run=maybe", strings.NewReader(csvContent))
+	rec := httptest.NewRecorder()
+	TransformCsv(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid dry_run to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestTransformCsvWorkbenchTerms(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			switch r.URL.Query().Get("name") {
+			case "Known, Kim":
+				_, _ = w.Write([]byte(`[{"tid":[{"value":10}]}]`))
+			case "Lehigh University":
+				_, _ = w.Write([]byte(`[{"tid":[{"value":62}]}]`))
+			default:
+				_, _ = w.Write([]byte(`[]`))
+			}
+		case "/jsonapi/taxonomy_term/person":
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		default:
+			t.Fatalf("unexpected request to %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+	csvContent := `Title,Contributor
+One,"{""name"":""relators:cre:person:Known, Kim""} ; {""name"":""relators:aut:person:New, Nia"",""institution"":""New College"",""institution_ror"":""https://ror.org/05dxps055"",""orcid"":""0000-0002-1825-0097""}"
+Two,"{""name"":""relators:aut:person:Lee, Lu"",""institution"":""Lehigh University"",""email"":""lu@lehigh.edu""} ; {""name"":""relators:pbl:corporate_body:New Press""}"
+Three,"{""name"":""relators:edt:person:Lee, Lu"",""email"":""lu.lee@lehigh.edu""}"
+`
+	req := httptest.NewRequest(http.MethodPost, "/?terms=workbench", strings.NewReader(csvContent))
+	req.Header.Set("Content-Type", "text/csv")
+	req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: RoleEditor}))
+	rec := httptest.NewRecorder()
+
+	TransformCsv(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	files := readZipFiles(t, rec.Body.Bytes())
+	for _, want := range []string{
+		`relators:cre:10|relators:aut:person:New, Nia`,
+		`relators:aut:person:Lee, Lu|relators:pbl:corporate_body:New Press`,
+	} {
+		if !strings.Contains(files["target.csv"], want) {
+			t.Errorf("expected %q in target.csv, got %s", want, files["target.csv"])
+		}
+	}
+	expected := "term_name,field_identifier\n" +
+		`New College,"{""attr0"":""ror"",""value"":""05dxps055""}"` + "\n" +
+		"New Press,\n"
+	if got := files["target.corporate_bodies.csv"]; got != expected {
+		t.Errorf("expected target.corporate_bodies.csv %q, got %q", expected, got)
+	}
+	// Workbench creates terms by name, so Lee, Lu is only listed once
+	expected = "term_name,field_email,field_identifier,field_relationships\n" +
+		`"New, Nia",,"{""attr0"":""orcid"",""value"":""0000-0002-1825-0097""}",schema:worksFor:corporate_body:New College` + "\n" +
+		`"Lee, Lu",lu@lehigh.edu,,schema:worksFor:62` + "\n"
+	if got := files["target.agents.csv"]; got != expected {
+		t.Errorf("expected target.agents.csv %q, got %q", expected, got)
+	}
+
+	// a dry run lists pending terms by the same reference target.csv uses
+	req = httptest.NewRequest(http.MethodPost, "/?terms=workbench&dry_run=true", strings.NewReader(csvContent))
+	req.Header.Set("Content-Type", "text/csv")
+	req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: RoleEditor}))
+	rec = httptest.NewRecorder()
+
+	TransformCsv(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	files = readZipFiles(t, rec.Body.Bytes())
+	if !strings.Contains(files["target.csv"], "relators:edt:person:Lee, Lu") {
+		t.Errorf("expected Lee, Lu referred to by name in target.csv, got %s", files["target.csv"])
+	}
+	expected = "placeholder,vocab,name,email,orcid,works_for,ror\n" +
+		"corporate_body:New College,corporate_body,New College,,,,05dxps055\n" +
+		`"person:New, Nia",person,"New, Nia",,0000-0002-1825-0097,corporate_body:New College,` + "\n" +
+		`"person:Lee, Lu",person,"Lee, Lu",lu@lehigh.edu,,62,` + "\n" +
+		"corporate_body:New Press,corporate_body,New Press,,,,\n"
+	if got := files[pendingTermsCSVName]; got != expected {
+		t.Errorf("expected manifest %q, got %q", expected, got)
+	}
+}
+
+func TestTransformCsvAuditsCreatedTerms(t *testing.T) {
+	var creates int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/term_from_term_name":
+			if r.URL.Query().Get("email") == "sam@lehigh.edu" {
+				_, _ = w.Write([]byte(`[{"tid":[{"value":321}],"name":[{"value":"Smith, Samuel"}]}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[]`))
+		case "/taxonomy/term":
+			creates++
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"tid":[{"value":%d}]}`, 100+creates)))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := os.WriteFile(path, []byte(`{"tid":1}`+"\n"), 0640); err != nil {
+		t.Fatalf("failed writing audit log: %v", err)
+	}
+	if err := ConfigureAuditLog(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = ConfigureAuditLog("")
+	}()
+
+	csvContent := `Title,Contributor
+One,"{""name"":""relators:aut:person:Smith, Sam"",""email"":""sam@lehigh.edu""} ; {""name"":""relators:pbl:corporate_body:New Press""}"
+`
+	req := httptest.NewRequest(http.MethodPost, "/?sheet=https%3A%2F%2Fdocs.google.com%2Fspreadsheets%2Fd%2Fabc", strings.NewReader(csvContent))
+	req.Header.Set("Content-Type", "text/csv")
+	req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "github-runner", Role: RoleIngester}))
+	rec := httptest.NewRecorder()
+
+	TransformCsv(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	expected := []termAuditEntry{
+		{Identity: "github-runner", Sheet: "https://docs.google.com/spreadsheets/d/abc", Vocab: "person", Name: "Smith, Sam", Email: "sam@lehigh.edu", Tid: 101, Reason: termReasonNameMismatch},
+		{Identity: "github-runner", Sheet: "https://docs.google.com/spreadsheets/d/abc", Vocab: "corporate_body", Name: "New Press", Tid: 102, Reason: termReasonNotFound},
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed reading audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 3 || lines[0] != `{"tid":1}` {
+		t.Fatalf("expected two lines appended to the audit log, got %q", lines)
+	}
+	for i, line := range lines[1:] {
+		var got termAuditEntry
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("failed decoding %q: %v", line, err)
+		}
+		if got.Time.IsZero() {
+			t.Errorf("expected a timestamp on %q", line)
+		}
+		got.Time = time.Time{}
+		if got != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], got)
+		}
+	}
+
+	created := readZipFiles(t, rec.Body.Bytes())[termsCreatedCSVName]
+	rows, err := csv.NewReader(strings.NewReader(created)).ReadAll()
+	if err != nil {
+		t.Fatalf("failed reading %s: %v", termsCreatedCSVName, err)
+	}
+	if len(rows) != 3 || rows[1][4] != "Smith, Sam" || rows[1][8] != termReasonNameMismatch || rows[2][7] != "102" {
+		t.Fatalf("unexpected %s: %q", termsCreatedCSVName, rows)
+	}
+}
+
+func TestTransformCsvRollsBackCreatedTerms(t *testing.T) {
+	tests := []struct {
+		name        string
+		failDelete  int
+		wantDeleted []int
+		wantBody    string
+	}{
+		{
+			name:        "deletes newest first",
+			wantDeleted: []int{102, 101},
+			wantBody:    "Error parsing CSV\n",
+		},
+		{
+			name:        "reports what it could not delete",
+			failDelete:  101,
+			wantDeleted: []int{102},
+			wantBody:    "/taxonomy/term/101 (corporate_body New College)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				creates int
+				deleted []int
+			)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/term_from_term_name":
+					_, _ = w.Write([]byte(`[]`))
+				case r.URL.Path == "/jsonapi/taxonomy_term/person":
+					_, _ = w.Write([]byte(`{"data":[]}`))
+				case r.Method == http.MethodPost && r.URL.Path == "/taxonomy/term":
+					creates++
+					_, _ = w.Write([]byte(fmt.Sprintf(`{"tid":[{"value":%d}]}`, 100+creates)))
+				case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/taxonomy/term/"):
+					if _, _, ok := r.BasicAuth(); !ok {
+						t.Fatal("expected the delete to authenticate")
+					}
+					var tid int
+					_, _ = fmt.Sscanf(r.URL.Path, "/taxonomy/term/%d", &tid)
+					if tid == tt.failDelete {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					deleted = append(deleted, tid)
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					t.Fatalf("unexpected request to %s %s", r.Method, r.URL.Path)
+				}
+			}))
+			defer ts.Close()
+			t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+			t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+			// row 3 fails after row 2 created an institution and a person
+			csvContent := `Title,Contributor
+One,
+Two,"{""name"":""relators:aut:person:New, Nia"",""institution"":""New College""}"
+Three,"not a contributor"
+`
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(csvContent))
+			req.Header.Set("Content-Type", "text/csv")
+			req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: RoleIngester}))
+			rec := httptest.NewRecorder()
+
+			TransformCsv(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if fmt.Sprint(deleted) != fmt.Sprint(tt.wantDeleted) {
+				t.Fatalf("expected terms %v deleted, got %v", tt.wantDeleted, deleted)
+			}
+			if !strings.HasSuffix(rec.Body.String(), tt.wantBody) {
+				t.Fatalf("expected response ending in %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+// A transform running while another creates a term doesn't get that term
+// from the shared cache, since a failure deletes it.
+func TestTransformCsvSharesCreatedTermsOnSuccess(t *testing.T) {
+	original := sharedTermCache
+	defer func() {
+		sharedTermCache = original
+	}()
+	ConfigureTermCache(time.Hour, time.Minute, 100)
+
+	var (
+		mu      sync.Mutex
+		creates int
+		deleted []int
+		broken  int
+	)
+	created := make(chan struct{})
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/term_from_term_name":
+			if r.URL.Query().Get("name") != "Broken, Bo" {
+				_, _ = w.Write([]byte(`[]`))
+				return
+			}
+			mu.Lock()
+			broken++
+			// the first lookup is the prefetch, the second comes after
+			// New, Nia was created
+			second := broken == 2
+			mu.Unlock()
+			if second {
+				close(created)
+				<-release
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.URL.Path == "/jsonapi/taxonomy_term/person":
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/taxonomy/term":
+			mu.Lock()
+			creates++
+			tid := 100 + creates
+			mu.Unlock()
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"tid":[{"value":%d}]}`, tid)))
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/taxonomy/term/"):
+			var tid int
+			_, _ = fmt.Sscanf(r.URL.Path, "/taxonomy/term/%d", &tid)
+			mu.Lock()
+			deleted = append(deleted, tid)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request to %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("FABRICATOR_TERM_LOOKUP_URL", ts.URL)
+	t.Setenv("FABRICATOR_DRUPAL_PASSWORD", "secret")
+
+	transform := func(csvContent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(csvContent))
+		req.Header.Set("Content-Type", "text/csv")
+		req = req.WithContext(WithIdentity(req.Context(), Identity{Name: "test", Role: RoleIngester}))
+		rec := httptest.NewRecorder()
+		TransformCsv(rec, req)
+		return rec
+	}
+
+	// the first transform creates New, Nia then fails on Broken, Bo
+	failed := make(chan *httptest.ResponseRecorder)
+	go func() {
+		failed <- transform(`Title,Contributor
+One,"{""name"":""relators:aut:person:New, Nia""}"
+Two,"{""name"":""relators:aut:person:Broken, Bo""}"
+`)
+	}()
+	<-created
+
+	rec := transform(`Title,Contributor
+Three,"{""name"":""relators:aut:person:New, Nia""}"
+`)
+	close(release)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := (<-failed).Code; got != http.StatusBadRequest {
+		t.Fatalf("expected the first transform to fail, got %d", got)
+	}
+	files := readZipFiles(t, rec.Body.Bytes())
+	if !strings.Contains(files["target.csv"], "relators:aut:102") {
+		t.Errorf("expected the second transform to use the term it created, got %s", files["target.csv"])
+	}
+	if fmt.Sprint(deleted) != "[101]" {
+		t.Errorf("expected only the failed transform's term deleted, got %v", deleted)
+	}
+
+	// once a transform succeeds its terms are shared
+	rec = transform(`Title,Contributor
+Four,"{""name"":""relators:aut:person:New, Nia""}"
+`)
+	files = readZipFiles(t, rec.Body.Bytes())
+	if !strings.Contains(files["target.csv"], "relators:aut:102") || creates != 2 {
+		t.Errorf("expected the shared term 102 to be reused, got %s after %d creates", files["target.csv"], creates)
+	}
+}
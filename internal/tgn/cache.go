@@ -0,0 +1,240 @@
+package tgn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long a resolved location is reused before Getty is
+// asked again. TGN hierarchies change rarely.
+const DefaultCacheTTL = 24 * time.Hour
+
+// DefaultCacheFlushInterval is how often a file backed process-wide cache
+// saves its snapshot when it has changed.
+const DefaultCacheFlushInterval = time.Minute
+
+// Cache holds resolved locations keyed by TGN ID so every check and transform
+// in the process shares the same lookups, whatever form of URI they use. When
+// path is set the cache is loaded from a JSON snapshot at that path, and Flush
+// saves it there.
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	path    string
+	entries map[string]cacheEntry
+	dirty   bool
+	now     func() time.Time
+
+	// saveMu keeps two flushes from writing the snapshot at once without
+	// holding up Get and Set.
+	saveMu sync.Mutex
+
+	// stop and stopped end the goroutine started by flushEvery.
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type cacheEntry struct {
+	Location    Location  `json:"location"`
+	Coordinates string    `json:"coordinates,omitempty"`
+	Expires     time.Time `json:"expires"`
+}
+
+var defaultCache = NewCache(DefaultCacheTTL)
+
+// NewCache returns an in-memory cache whose entries live for ttl.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		entries: map[string]cacheEntry{},
+		now:     time.Now,
+	}
+}
+
+// OpenCache returns a cache backed by the JSON snapshot at path, loading any
+// unexpired entries already saved there. A missing file starts empty.
+func OpenCache(ttl time.Duration, path string) (*Cache, error) {
+	c := NewCache(ttl)
+	c.path = path
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read TGN cache %s: %w", path, err)
+	}
+	var saved map[string]cacheEntry
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return nil, fmt.Errorf("invalid TGN cache %s: %w", path, err)
+	}
+	now := c.now()
+	for key, e := range saved {
+		if !now.Before(e.Expires) {
+			continue
+		}
+		// older snapshots are keyed by URI
+		if id, err := ParseID(key); err == nil {
+			key = id
+		}
+		c.entries[key] = e
+	}
+
+	return c, nil
+}
+
+// ConfigureCache replaces the process-wide cache used by GetLocationFromTGN.
+// An empty path keeps the cache in memory only, otherwise its snapshot is
+// saved every DefaultCacheFlushInterval and by CloseCache. It should be called
+// once at startup.
+func ConfigureCache(ttl time.Duration, path string) error {
+	c := NewCache(ttl)
+	if path != "" {
+		var err error
+		if c, err = OpenCache(ttl, path); err != nil {
+			return err
+		}
+		c.flushEvery(DefaultCacheFlushInterval)
+	}
+	if err := defaultCache.Close(); err != nil {
+		slog.Error("Unable to save TGN cache", "path", defaultCache.path, "err", err)
+	}
+	defaultCache = c
+
+	return nil
+}
+
+// CloseCache stops saving the process-wide cache's snapshot periodically and
+// saves it if it has changed. It should be called before the process exits.
+func CloseCache() error {
+	return defaultCache.Close()
+}
+
+// flushEvery saves the snapshot every interval until Close.
+func (c *Cache) flushEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	c.stop, c.stopped = make(chan struct{}), make(chan struct{})
+	go func(stop, stopped chan struct{}) {
+		defer close(stopped)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := c.Flush(); err != nil {
+					slog.Error("Unable to save TGN cache", "path", c.path, "err", err)
+				}
+			}
+		}
+	}(c.stop, c.stopped)
+}
+
+// Close stops any periodic saving, waiting for a save in progress, and then
+// saves the snapshot if it has changed.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	stop, stopped := c.stop, c.stopped
+	c.stop, c.stopped = nil, nil
+	c.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+
+	return c.Flush()
+}
+
+// Get returns a copy of the cached location for uri.
+func (c *Cache) Get(uri string) (*Location, bool) {
+	key, err := ParseID(uri)
+	if err != nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.Expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	loc := e.Location
+	loc.Coordinates = e.Coordinates
+
+	return &loc, true
+}
+
+// Set stores loc for uri. A file backed cache saves it on the next Flush. A
+// URI that isn't a TGN place isn't stored.
+func (c *Cache) Set(uri string, loc *Location) {
+	key, err := ParseID(uri)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{
+		Location:    *loc,
+		Coordinates: loc.Coordinates,
+		Expires:     c.now().Add(c.ttl),
+	}
+	c.dirty = true
+}
+
+// Flush saves the snapshot when the cache is file backed and has changed
+// since it was last saved.
+func (c *Cache) Flush() error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	if c.path == "" || !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	raw, err := json.Marshal(c.entries)
+	c.dirty = false
+	c.mu.Unlock()
+
+	if err == nil {
+		err = c.save(raw)
+	}
+	if err != nil {
+		// try again on the next flush
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// save writes raw to a temp file and renames it into place so a crash never
+// leaves a half written cache.
+func (c *Cache) save(raw []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".tgn-cache-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path)
+}
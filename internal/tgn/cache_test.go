@@ -0,0 +1,144 @@
+package tgn
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheKeysByCanonicalURI(t *testing.T) {
+	c := NewCache(time.Hour)
+	loc := &Location{City: "Bethlehem", Coordinates: "40.6167,-75.35"}
+	c.Set("http://vocab.getty.edu/page/tgn/7013416", loc)
+
+	for _, uri := range []string{
+		"http://vocab.getty.edu/page/tgn/7013416",
+		"http://vocab.getty.edu/tgn/7013416",
+		"http://vocab.getty.edu/tgn/7013416.json",
+		"http://vocab.getty.edu/tgn/7013416/",
+		"https://vocab.getty.edu/page/tgn/7013416",
+		"https://vocab.getty.edu/tgn/7013416.json",
+	} {
+		got, ok := c.Get(uri)
+		if !ok {
+			t.Fatalf("expected a cache hit for %s", uri)
+		}
+		if *got != *loc {
+			t.Fatalf("expected %+v, got %+v", loc, got)
+		}
+	}
+
+	// callers get a copy they can't use to change the cached entry
+	got, _ := c.Get("http://vocab.getty.edu/tgn/7013416")
+	got.City = "Allentown"
+	if again, _ := c.Get("http://vocab.getty.edu/tgn/7013416"); again.City != "Bethlehem" {
+		t.Fatalf("cached entry was modified: %+v", again)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewCache(time.Hour)
+	c.now = func() time.Time { return now }
+	c.Set("http://vocab.getty.edu/tgn/1", &Location{City: "A"})
+
+	now = now.Add(59 * time.Minute)
+	if _, ok := c.Get("http://vocab.getty.edu/tgn/1"); !ok {
+		t.Fatal("expected entry before the TTL")
+	}
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("http://vocab.getty.edu/tgn/1"); ok {
+		t.Fatal("expected entry to expire after the TTL")
+	}
+}
+
+func TestCacheSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tgn.json")
+
+	c, err := OpenCache(time.Hour, path)
+	if err != nil {
+		t.Fatalf("unexpected error opening a missing snapshot: %v", err)
+	}
+	loc := &Location{Country: "United States", City: "Coplay", Coordinates: "40.6667,-75.4833"}
+	c.Set("http://vocab.getty.edu/tgn/2087483", loc)
+	c.Set("http://vocab.getty.edu/tgn/1", &Location{City: "Stale"})
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected Set to leave saving to Flush, got %v", err)
+	}
+	c.entries["1"] = cacheEntry{Expires: time.Now().Add(-time.Minute)}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("unexpected error saving: %v", err)
+	}
+	// nothing has changed since, so there is nothing to save
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed removing snapshot: %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("unexpected error flushing: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected an unchanged cache not to be saved again, got %v", err)
+	}
+	c.Set("http://vocab.getty.edu/tgn/2087483", loc)
+	if err := c.Flush(); err != nil {
+		t.Fatalf("unexpected error saving: %v", err)
+	}
+
+	reopened, err := OpenCache(time.Hour, path)
+	if err != nil {
+		t.Fatalf("unexpected error reopening: %v", err)
+	}
+	got, ok := reopened.Get("http://vocab.getty.edu/page/tgn/2087483")
+	if !ok || *got != *loc {
+		t.Fatalf("expected %+v from the snapshot, got %+v", loc, got)
+	}
+	if len(reopened.entries) != 1 {
+		t.Fatalf("expected expired entries to be dropped on load, got %d entries", len(reopened.entries))
+	}
+}
+
+func TestCacheFlushesUntilClosed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tgn.json")
+	c, err := OpenCache(time.Hour, path)
+	if err != nil {
+		t.Fatalf("unexpected error opening a missing snapshot: %v", err)
+	}
+	c.flushEvery(time.Millisecond)
+
+	c.Set("http://vocab.getty.edu/tgn/1", &Location{City: "A"})
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the snapshot to be saved periodically")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Set("http://vocab.getty.edu/tgn/2", &Location{City: "B"})
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+	reopened, err := OpenCache(time.Hour, path)
+	if err != nil {
+		t.Fatalf("unexpected error reopening: %v", err)
+	}
+	if _, ok := reopened.Get("http://vocab.getty.edu/tgn/2"); !ok {
+		t.Fatal("expected Close to save the snapshot")
+	}
+
+	// nothing saves the snapshot once closed
+	c.Set("http://vocab.getty.edu/tgn/3", &Location{City: "C"})
+	time.Sleep(20 * time.Millisecond)
+	c.mu.Lock()
+	dirty := c.dirty
+	c.mu.Unlock()
+	if !dirty {
+		t.Fatal("expected the periodic save to stop on Close")
+	}
+}
@@ -0,0 +1,118 @@
+package tgn
+
+import (
+	"regexp"
+	"strings"
+)
+
+// placeField is the Location field a TGN place type fills.
+type placeField int
+
+const (
+	fieldNone placeField = iota
+	fieldContinent
+	fieldCountry
+	fieldRegion
+	fieldState
+	fieldCounty
+	fieldCity
+	fieldCitySection
+)
+
+// aatPlaceTypes maps the AAT concepts TGN uses in classified_as to a Location
+// field. Types not listed here fall back to matching on their label.
+var aatPlaceTypes = map[string]placeField{
+	"300128176": fieldContinent,   // continents
+	"300128207": fieldCountry,     // nations
+	"300232420": fieldCountry,     // sovereign states
+	"300387506": fieldCountry,     // countries (sovereign states)
+	"300000778": fieldRegion,      // general regions
+	"300236112": fieldRegion,      // regions (administrative divisions)
+	"300000776": fieldState,       // states (political divisions)
+	"300000774": fieldState,       // provinces
+	"300000771": fieldCounty,      // counties
+	"300008347": fieldCity,        // inhabited places
+	"300008389": fieldCity,        // cities
+	"300008375": fieldCity,        // towns
+	"300008372": fieldCity,        // villages
+	"300000745": fieldCitySection, // neighborhoods
+}
+
+// placeTypeLabels matches a classified_as label, singular or plural and without
+// any "(political divisions)" style qualifier.
+var placeTypeLabels = map[string]placeField{
+	"continent":       fieldContinent,
+	"nation":          fieldCountry,
+	"country":         fieldCountry,
+	"sovereign state": fieldCountry,
+	"general region":  fieldRegion,
+	"region":          fieldRegion,
+	"state":           fieldState,
+	"province":        fieldState,
+	"territory":       fieldState,
+	"county":          fieldCounty,
+	"inhabited place": fieldCity,
+	"city":            fieldCity,
+	"town":            fieldCity,
+	"village":         fieldCity,
+	"borough":         fieldCity,
+	"township":        fieldCity,
+	"neighborhood":    fieldCitySection,
+}
+
+var labelQualifier = regexp.MustCompile(`\s*\([^)]*\)$`)
+
+// placeType returns the field for the first classified_as entry this package
+// recognizes.
+func placeType(p Place) placeField {
+	for _, c := range p.ClassifiedAs {
+		if field, ok := aatPlaceTypes[placeID(c.ID)]; ok {
+			return field
+		}
+		label := labelQualifier.ReplaceAllString(strings.ToLower(strings.TrimSpace(c.Label)), "")
+		for _, l := range []string{label, singular(label)} {
+			if field, ok := placeTypeLabels[l]; ok {
+				return field
+			}
+		}
+	}
+	return fieldNone
+}
+
+func singular(label string) string {
+	switch {
+	case strings.HasSuffix(label, "ies"):
+		return strings.TrimSuffix(label, "ies") + "y"
+	case strings.HasSuffix(label, "s"):
+		return strings.TrimSuffix(label, "s")
+	}
+	return label
+}
+
+// set fills field with label unless a nearer place already filled it.
+func (l *Location) set(field placeField, label string) bool {
+	var target *string
+	switch field {
+	case fieldContinent:
+		target = &l.Continent
+	case fieldCountry:
+		target = &l.Country
+	case fieldRegion:
+		target = &l.Region
+	case fieldState:
+		target = &l.State
+	case fieldCounty:
+		target = &l.County
+	case fieldCity:
+		target = &l.City
+	case fieldCitySection:
+		target = &l.CitySection
+	default:
+		return false
+	}
+	if *target != "" {
+		return false
+	}
+	*target = label
+	return true
+}
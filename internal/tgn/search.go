@@ -0,0 +1,174 @@
+package tgn
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// DefaultSearchLimit is how many candidates Search returns when limit <= 0.
+const DefaultSearchLimit = 5
+
+// Candidate is a TGN place that might be what an editor meant by a name.
+type Candidate struct {
+	URI   string `json:"uri"`
+	Label string `json:"label"`
+	// Parents is Getty's comma separated list of broader places, nearest first.
+	Parents  string    `json:"parents"`
+	Type     string    `json:"type,omitempty"`
+	Location *Location `json:"location,omitempty"`
+}
+
+// searchQuery finds places with a name containing every word of the search
+// term. It is formatted with the escaped term and a limit.
+const searchQuery = `PREFIX luc: <http://www.ontotext.com/owlim/lucene#>
+PREFIX gvp: <http://vocab.getty.edu/ontology#>
+PREFIX skos: <http://www.w3.org/2004/02/skos/core#>
+PREFIX xl: <http://www.w3.org/2008/05/skos-xl#>
+PREFIX tgn: <http://vocab.getty.edu/tgn/>
+SELECT ?subject ?term ?parents ?type WHERE {
+  ?subject luc:term "%s" ;
+    skos:inScheme tgn: ;
+    gvp:prefLabelGVP [xl:literalForm ?term] ;
+    gvp:parentStringAbbrev ?parents .
+  OPTIONAL { ?subject gvp:placeTypePreferred [gvp:prefLabelGVP [xl:literalForm ?type]] }
+}
+LIMIT %d`
+
+type sparqlResponse struct {
+	Results struct {
+		Bindings []map[string]struct {
+			Value string `json:"value"`
+		} `json:"bindings"`
+	} `json:"results"`
+}
+
+// Search looks up TGN places by name using DefaultClient.
+func Search(ctx context.Context, name string, limit int) ([]Candidate, error) {
+	return DefaultClient.Search(ctx, name, limit)
+}
+
+// Search looks up TGN places by name with the Getty SPARQL endpoint. Anything
+// after the first comma narrows the results, e.g. "Bethlehem, PA" prefers
+// places with Pennsylvania among their parents. Each candidate's full
+// hierarchy is resolved when Getty can provide it.
+func (c *Client) Search(ctx context.Context, name string, limit int) ([]Candidate, error) {
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	term, qualifiers := splitPlaceName(name)
+	if term == "" {
+		return nil, fmt.Errorf("no place name to search for")
+	}
+
+	// ask for extra rows so qualifiers have something to choose from
+	query := fmt.Sprintf(searchQuery, escapeSPARQLString(term), limit*5)
+	var resp sparqlResponse
+	if err := c.getJSON(ctx, c.sparqlURL()+"?query="+url.QueryEscape(query), &resp); err != nil {
+		return nil, err
+	}
+
+	var matched, others []Candidate
+	for _, b := range resp.Results.Bindings {
+		candidate := Candidate{
+			URI:     b["subject"].Value,
+			Label:   b["term"].Value,
+			Parents: b["parents"].Value,
+			Type:    b["type"].Value,
+		}
+		if candidate.URI == "" {
+			continue
+		}
+		if parentsMatch(candidate.Parents, qualifiers) {
+			matched = append(matched, candidate)
+		} else {
+			others = append(others, candidate)
+		}
+	}
+	candidates := append(matched, others...)
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	for i := range candidates {
+		loc, err := c.Resolve(ctx, candidates[i].URI)
+		if err != nil {
+			slog.Warn("Unable to resolve TGN candidate", "uri", candidates[i].URI, "err", err)
+			continue
+		}
+		candidates[i].Location = loc
+	}
+
+	return candidates, nil
+}
+
+func (c *Client) sparqlURL() string {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/sparql.json"
+}
+
+// splitPlaceName splits "Bethlehem, PA" into the name to search for and the
+// qualifiers that should appear among the place's parents.
+func splitPlaceName(name string) (string, []string) {
+	parts := strings.Split(name, ",")
+	term := strings.TrimSpace(parts[0])
+	// a qualifier in parentheses like "Bethlehem (Pa.)" narrows the search too
+	if i := strings.Index(term, "("); i > 0 {
+		parts = append(parts, strings.Trim(term[i:], "() "))
+		term = strings.TrimSpace(term[:i])
+	}
+
+	var qualifiers []string
+	for _, q := range parts[1:] {
+		q = strings.TrimSuffix(strings.TrimSpace(q), ".")
+		if q == "" {
+			continue
+		}
+		if state, ok := usStateAbbreviations[strings.ToUpper(q)]; ok {
+			q = state
+		}
+		qualifiers = append(qualifiers, q)
+	}
+	return term, qualifiers
+}
+
+func parentsMatch(parents string, qualifiers []string) bool {
+	if len(qualifiers) == 0 {
+		return false
+	}
+	parents = strings.ToLower(parents)
+	for _, q := range qualifiers {
+		if !strings.Contains(parents, strings.ToLower(q)) {
+			return false
+		}
+	}
+	return true
+}
+
+func escapeSPARQLString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ", "\r", " ").Replace(s)
+}
+
+// usStateAbbreviations expands the postal and AP style abbreviations editors
+// commonly type after a US place name.
+var usStateAbbreviations = map[string]string{
+	"AL": "Alabama", "AK": "Alaska", "AZ": "Arizona", "AR": "Arkansas",
+	"CA": "California", "CO": "Colorado", "CT": "Connecticut", "DE": "Delaware",
+	"DC": "District of Columbia", "FL": "Florida", "GA": "Georgia", "HI": "Hawaii",
+	"ID": "Idaho", "IL": "Illinois", "IN": "Indiana", "IA": "Iowa",
+	"KS": "Kansas", "KY": "Kentucky", "LA": "Louisiana", "ME": "Maine",
+	"MD": "Maryland", "MA": "Massachusetts", "MI": "Michigan", "MN": "Minnesota",
+	"MS": "Mississippi", "MO": "Missouri", "MT": "Montana", "NE": "Nebraska",
+	"NV": "Nevada", "NH": "New Hampshire", "NJ": "New Jersey", "NM": "New Mexico",
+	"NY": "New York", "NC": "North Carolina", "ND": "North Dakota", "OH": "Ohio",
+	"OK": "Oklahoma", "OR": "Oregon", "PA": "Pennsylvania", "RI": "Rhode Island",
+	"SC": "South Carolina", "SD": "South Dakota", "TN": "Tennessee", "TX": "Texas",
+	"UT": "Utah", "VT": "Vermont", "VA": "Virginia", "WA": "Washington",
+	"WV": "West Virginia", "WI": "Wisconsin", "WY": "Wyoming",
+	"N.J": "New Jersey", "N.Y": "New York",
+}
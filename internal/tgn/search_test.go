@@ -0,0 +1,98 @@
+package tgn
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPlaceName(t *testing.T) {
+	tests := map[string]struct {
+		name       string
+		term       string
+		qualifiers []string
+	}{
+		"plain":          {name: "Bethlehem", term: "Bethlehem"},
+		"state":          {name: "Bethlehem, PA", term: "Bethlehem", qualifiers: []string{"Pennsylvania"}},
+		"parenthesised":  {name: "Bethlehem (Pa.)", term: "Bethlehem", qualifiers: []string{"Pennsylvania"}},
+		"several":        {name: "Paris, Texas, United States", term: "Paris", qualifiers: []string{"Texas", "United States"}},
+		"blank trailing": {name: "Easton, ", term: "Easton"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			term, qualifiers := splitPlaceName(tc.name)
+			if term != tc.term || !reflect.DeepEqual(qualifiers, tc.qualifiers) {
+				t.Fatalf("expected %q %v, got %q %v", tc.term, tc.qualifiers, term, qualifiers)
+			}
+		})
+	}
+}
+
+// sparqlHandler answers SPARQL searches with rows, and serves place fixtures
+// for everything else.
+func sparqlHandler(t *testing.T, wantTerm string, rows [][4]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sparql.json" {
+			fixtures().ServeHTTP(w, r)
+			return
+		}
+		if q := r.URL.Query().Get("query"); !strings.Contains(q, `luc:term "`+wantTerm+`"`) {
+			t.Errorf("expected a search for %q, got %s", wantTerm, q)
+		}
+		var resp sparqlResponse
+		for _, row := range rows {
+			binding := map[string]struct {
+				Value string `json:"value"`
+			}{}
+			for i, key := range []string{"subject", "term", "parents", "type"} {
+				binding[key] = struct {
+					Value string `json:"value"`
+				}{row[i]}
+			}
+			resp.Results.Bindings = append(resp.Results.Bindings, binding)
+		}
+		w.Header().Set("Content-Type", "application/sparql-results+json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+func TestSearch(t *testing.T) {
+	client := newTestClient(t, sparqlHandler(t, "Bethlehem", [][4]string{
+		{"http://vocab.getty.edu/tgn/7000001", "Bethlehem", "West Bank, Palestine, Asia", "inhabited place"},
+		{"http://vocab.getty.edu/tgn/7013416", "Bethlehem", "Northampton, Pennsylvania, United States, North and Central America", "city"},
+	}))
+
+	candidates, err := client.Search(context.Background(), "Bethlehem, PA", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %+v", candidates)
+	}
+
+	// the Pennsylvania Bethlehem matches the qualifier so it comes first, with
+	// its hierarchy resolved
+	first := candidates[0]
+	if first.URI != "http://vocab.getty.edu/tgn/7013416" || first.Location == nil || first.Location.County != "Northampton" {
+		t.Fatalf("unexpected first candidate %+v", first)
+	}
+	// there is no fixture for the other one, it is still suggested without a hierarchy
+	if candidates[1].Location != nil {
+		t.Fatalf("expected no location for %+v", candidates[1])
+	}
+}
+
+func TestSearchEscapesTerm(t *testing.T) {
+	client := newTestClient(t, sparqlHandler(t, `Bob\"s Town`, nil))
+	candidates, err := client.Search(context.Background(), `Bob"s Town`, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 0 {
+		t.Fatalf("expected no candidates, got %+v", candidates)
+	}
+}
@@ -1,13 +1,18 @@
 package tgn
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Location represents the hierarchical location details stored in Islandora
@@ -16,17 +21,24 @@ type Location struct {
 	State       string `json:"state"`
 	County      string `json:"county"`
 	City        string `json:"city"`
+	Continent   string `json:"continent,omitempty"`
+	Region      string `json:"region,omitempty"`
+	CitySection string `json:"city_section,omitempty"`
 	Coordinates string `json:"-"`
 }
 
+// Reference is a linked art link to another TGN place or AAT concept.
+type Reference struct {
+	ID    string `json:"id"`
+	Label string `json:"_label"`
+}
+
 // Place represents the TGN data
 type Place struct {
-	ID     string `json:"id"`
-	Label  string `json:"_label"`
-	PartOf []struct {
-		ID    string `json:"id"`
-		Label string `json:"_label"`
-	} `json:"part_of"`
+	ID           string      `json:"id"`
+	Label        string      `json:"_label"`
+	ClassifiedAs []Reference `json:"classified_as"`
+	PartOf       []Reference `json:"part_of"`
 	IdentifiedBy []struct {
 		Type  string `json:"type"`
 		Value string `json:"value"`
@@ -72,110 +84,255 @@ func canonicalJSONURI(uri string) string {
 	return u + ".json"
 }
 
-// GetLocationFromTGN fetches the location information from a TGN URI.
-func GetLocationFromTGN(uri string) (*Location, error) {
-	req, err := http.NewRequest("GET", canonicalJSONURI(uri), nil)
-	if err != nil {
-		slog.Error("Unable to create hierarchy request", "url", uri, "err", err)
-		return nil, err
+// ErrInvalidURI is returned for a URI that isn't a Getty TGN place, e.g. the
+// AAT concept http://vocab.getty.edu/aat/300008347.
+var ErrInvalidURI = errors.New("not a TGN URI, expected http://vocab.getty.edu/page/tgn/<id>")
+
+var tgnURIPattern = regexp.MustCompile(`^https?://vocab\.getty\.edu/(?:page/)?tgn/(\d+)(?:\.json|/)?$`)
+
+// ParseID returns the TGN ID in a vocab.getty.edu/tgn/{id} or
+// vocab.getty.edu/page/tgn/{id} URI.
+func ParseID(uri string) (string, error) {
+	m := tgnURIPattern.FindStringSubmatch(strings.TrimSpace(uri))
+	if m == nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidURI, uri)
 	}
+	return m[1], nil
+}
 
-	req.Header.Set("Accept", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching data: %v", err)
+// placeID returns the ID at the end of a Getty URI, TGN or AAT, for comparing
+// places and place types Getty links to.
+func placeID(uri string) string {
+	u := strings.TrimSuffix(canonicalJSONURI(uri), ".json")
+	return u[strings.LastIndex(u, "/")+1:]
+}
+
+// DefaultBaseURL is where TGN linked data is fetched from.
+const DefaultBaseURL = "https://vocab.getty.edu"
+
+// Client resolves TGN URIs into locations.
+type Client struct {
+	// HTTPClient makes the requests. Its Timeout bounds each attempt.
+	HTTPClient *http.Client
+	// BaseURL is where TGN places are fetched from, e.g. a mirror or a test
+	// server. Empty uses DefaultBaseURL.
+	BaseURL string
+	// MaxRetries is how many times a request is retried after a network
+	// error, a 5xx or a 429.
+	MaxRetries int
+	// Backoff is the wait before the first retry. It doubles each attempt
+	// unless the server sends Retry-After.
+	Backoff time.Duration
+	// Cache stores resolved locations. Nil uses the process-wide cache.
+	Cache *Cache
+}
+
+// NewClient returns a Client with the default timeout, retries and base URL.
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		BaseURL:    DefaultBaseURL,
+		MaxRetries: 3,
+		Backoff:    500 * time.Millisecond,
 	}
-	defer resp.Body.Close()
+}
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+// DefaultClient is used by GetLocationFromTGN.
+var DefaultClient = NewClient()
+
+// GetLocationFromTGN returns the location information for a TGN URI using
+// DefaultClient.
+func GetLocationFromTGN(uri string) (*Location, error) {
+	return DefaultClient.Resolve(context.Background(), uri)
+}
+
+func (c *Client) cache() *Cache {
+	if c.Cache != nil {
+		return c.Cache
 	}
+	return defaultCache
+}
 
-	// Parse the JSON data
-	var place Place
-	if err := json.Unmarshal(body, &place); err != nil {
-		preview := string(body)
-		if len(preview) > 200 {
-			preview = preview[:200]
-		}
-		return nil, fmt.Errorf("error parsing JSON (status %d, content-type %q): %v; body: %s",
-			resp.StatusCode, resp.Header.Get("Content-Type"), err, preview)
+// Resolve returns the location information for a TGN URI, using the cache
+// before asking Getty. A URI that isn't a TGN place returns ErrInvalidURI.
+func (c *Client) Resolve(ctx context.Context, uri string) (*Location, error) {
+	if _, err := ParseID(uri); err != nil {
+		return nil, err
+	}
+	if loc, ok := c.cache().Get(uri); ok {
+		return loc, nil
+	}
+
+	place, err := c.fetchPlace(ctx, uri)
+	if err != nil {
+		return nil, err
 	}
 
 	location := &Location{
 		Coordinates: extractCoordinates(place),
 	}
 
-	// Recursively process the hierarchy
-	err = resolveHierarchy(place, location, 0)
+	err = c.resolveHierarchy(ctx, place, location)
 	if err != nil {
 		return nil, err
 	}
+	c.cache().Set(uri, location)
 
 	return location, nil
 }
 
-// resolveHierarchy recursively resolves the hierarchy from the place data.
-func resolveHierarchy(place Place, location *Location, depth int) error {
-	// If this place has no parent, it must be the city
-	if len(place.PartOf) == 0 {
-		location.Country = place.Label
-		return nil
+// resolveHierarchy walks up from place, nearest ancestors first, filling each
+// Location field from the nearest place whose TGN place type maps to it. When
+// a place has several parents they are visited in TGN ID order so the result
+// doesn't depend on how Getty happens to order part_of.
+func (c *Client) resolveHierarchy(ctx context.Context, place Place, location *Location) error {
+	// the place itself is assumed to be the city when TGN doesn't say
+	if !location.set(placeType(place), place.Label) {
+		location.set(fieldCity, place.Label)
 	}
 
-	// Recursively resolve the parent hierarchy
-	parentPlace, err := fetchPlaceData(place.PartOf[0].ID + ".json")
-	if err != nil {
-		return err
+	queue := []Place{place}
+	seen := map[string]bool{placeID(place.ID): true}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		parents := make([]Reference, len(current.PartOf))
+		copy(parents, current.PartOf)
+		sort.Slice(parents, func(i, j int) bool {
+			return placeID(parents[i].ID) < placeID(parents[j].ID)
+		})
+		for _, ref := range parents {
+			id := placeID(ref.ID)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			parent, err := c.fetchPlace(ctx, ref.ID)
+			if err != nil {
+				return err
+			}
+			location.set(placeType(parent), parent.Label)
+			queue = append(queue, parent)
+		}
 	}
-	err = resolveHierarchy(parentPlace, location, depth+1)
+
+	return nil
+}
+
+// placeURL is where the JSON for a TGN URI is fetched from.
+func (c *Client) placeURL(uri string) (string, error) {
+	id, err := ParseID(uri)
 	if err != nil {
-		return err
+		return "", err
 	}
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return fmt.Sprintf("%s/tgn/%s.json", strings.TrimSuffix(base, "/"), id), nil
+}
 
-	// Assign the correct label based on depth
-	switch depth {
-	case 0:
-		location.City = place.Label
-	case 1:
-		location.County = place.Label
-	case 2:
-		location.State = place.Label
-	case 3:
-		location.Country = place.Label
+// fetchPlace fetches the JSON data for a given TGN URI.
+func (c *Client) fetchPlace(ctx context.Context, uri string) (Place, error) {
+	url, err := c.placeURL(uri)
+	if err != nil {
+		return Place{}, err
 	}
+	var place Place
+	if err := c.getJSON(ctx, url, &place); err != nil {
+		return Place{}, err
+	}
+	return place, nil
+}
 
-	return nil
+// getJSON decodes the JSON at url into v, retrying transient failures.
+func (c *Client) getJSON(ctx context.Context, url string, v any) error {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.getJSONOnce(ctx, url, v)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if retryAfter < 0 || attempt >= c.MaxRetries {
+			return lastErr
+		}
+
+		wait := c.Backoff << attempt
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		slog.Warn("Retrying TGN request", "url", url, "attempt", attempt+1, "wait", wait, "err", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+	}
 }
 
-// fetchPlaceData fetches the JSON data for a given TGN URI.
-func fetchPlaceData(uri string) (Place, error) {
-	req, err := http.NewRequest("GET", uri, nil)
+// getJSONOnce makes a single request. retryAfter is negative when the error
+// is not worth retrying, otherwise it is the server's requested wait (zero
+// when it didn't send one).
+func (c *Client) getJSONOnce(ctx context.Context, url string, v any) (retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		slog.Error("Unable to create hierarchy request", "url", uri, "err", err)
-		return Place{}, err
+		slog.Error("Unable to create TGN request", "url", url, "err", err)
+		return -1, err
 	}
 
 	req.Header.Set("Accept", "application/json")
-	client := &http.Client{}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return Place{}, fmt.Errorf("error fetching data: %v", err)
+		if ctx.Err() != nil {
+			return -1, fmt.Errorf("error fetching data: %w", err)
+		}
+		return 0, fmt.Errorf("error fetching data: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return Place{}, fmt.Errorf("error reading response body: %v", err)
+		return 0, fmt.Errorf("error reading response body: %v", err)
 	}
 
-	var place Place
-	if err := json.Unmarshal(body, &place); err != nil {
-		return Place{}, fmt.Errorf("error parsing JSON: %v", err)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return retryAfterHeader(resp), fmt.Errorf("error fetching data: %s returned %d", url, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("error fetching data: %s returned %d", url, resp.StatusCode)
 	}
 
-	return place, nil
+	// Parse the JSON data
+	if err := json.Unmarshal(body, v); err != nil {
+		preview := string(body)
+		if len(preview) > 200 {
+			preview = preview[:200]
+		}
+		return -1, fmt.Errorf("error parsing JSON (status %d, content-type %q): %v; body: %s",
+			resp.StatusCode, resp.Header.Get("Content-Type"), err, preview)
+	}
+
+	return 0, nil
+}
+
+// maxRetryAfter caps how long a Retry-After header can make us wait.
+const maxRetryAfter = 30 * time.Second
+
+// retryAfterHeader parses a Retry-After header given in seconds.
+func retryAfterHeader(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryAfter)
 }
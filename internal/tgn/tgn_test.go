@@ -1,17 +1,43 @@
 package tgn
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
+// newTestClient points a Client at handler with its own cache and fast retries.
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := NewClient()
+	c.BaseURL = server.URL
+	c.Backoff = time.Millisecond
+	c.Cache = NewCache(time.Hour)
+	return c
+}
+
+// fixtures serves the Getty linked art JSON saved in testdata.
+func fixtures() http.Handler {
+	return http.FileServer(http.Dir("testdata"))
+}
+
 func TestGetLocationFromTGN(t *testing.T) {
 	tests := map[string]struct {
 		URI      string
 		Expected *Location
 	}{
 		"Test Bethlehem": {
+			// part of both Lehigh and Northampton counties, the lower TGN ID wins
 			URI: "http://vocab.getty.edu/page/tgn/7013416",
 			Expected: &Location{
+				Continent:   "North and Central America",
 				Country:     "United States",
 				State:       "Pennsylvania",
 				County:      "Northampton",
@@ -22,6 +48,7 @@ func TestGetLocationFromTGN(t *testing.T) {
 		"Test Coplay": {
 			URI: "http://vocab.getty.edu/page/tgn/2087483",
 			Expected: &Location{
+				Continent:   "North and Central America",
 				Country:     "United States",
 				State:       "Pennsylvania",
 				County:      "Lehigh",
@@ -31,22 +58,60 @@ func TestGetLocationFromTGN(t *testing.T) {
 		},
 		"Test Luxembourg": {
 			URI: "http://vocab.getty.edu/page/tgn/7003514",
-			// Top-level continental record: no county/state, but should still
-			// have a country, city/place label, and coordinates.
-			Expected: nil,
+			// a nation directly under a continent has no state, county or city
+			Expected: &Location{
+				Continent:   "Europe",
+				Country:     "Luxembourg",
+				Coordinates: "49.75,6.1667",
+			},
+		},
+		"Test neighborhood": {
+			URI: "http://vocab.getty.edu/tgn/7730001",
+			Expected: &Location{
+				Continent:   "North and Central America",
+				Country:     "United States",
+				State:       "Pennsylvania",
+				County:      "Northampton",
+				City:        "Bethlehem",
+				CitySection: "South Side",
+				Coordinates: "40.6084,-75.3744",
+			},
+		},
+		"Test county": {
+			URI: "http://vocab.getty.edu/tgn/2001845",
+			Expected: &Location{
+				Continent:   "North and Central America",
+				Country:     "United States",
+				State:       "Pennsylvania",
+				County:      "Northampton",
+				Coordinates: "40.75,-75.3",
+			},
+		},
+		"Test region": {
+			URI: "http://vocab.getty.edu/tgn/7730003",
+			Expected: &Location{
+				Continent:   "North and Central America",
+				Country:     "United States",
+				Region:      "Lehigh Valley",
+				State:       "Pennsylvania",
+				County:      "Northampton",
+				City:        "Easton",
+				Coordinates: "40.6883,-75.2208",
+			},
 		},
 	}
 
+	client := newTestClient(t, fixtures())
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			location, err := GetLocationFromTGN(tc.URI)
+			location, err := client.Resolve(context.Background(), tc.URI)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
 
 			t.Logf("%s -> %+v", tc.URI, location)
 
-			if tc.Expected != nil && *location != *tc.Expected {
+			if *location != *tc.Expected {
 				t.Errorf("expected %+v, got %+v", tc.Expected, location)
 			}
 
@@ -56,3 +121,171 @@ func TestGetLocationFromTGN(t *testing.T) {
 		})
 	}
 }
+
+func TestPlaceType(t *testing.T) {
+	tests := map[string]struct {
+		classifiedAs []Reference
+		expected     placeField
+	}{
+		"AAT ID": {
+			classifiedAs: []Reference{{ID: "http://vocab.getty.edu/aat/300000771", Label: "something else"}},
+			expected:     fieldCounty,
+		},
+		"plural label with qualifier": {
+			classifiedAs: []Reference{{ID: "http://vocab.getty.edu/aat/1", Label: "Territories (political divisions)"}},
+			expected:     fieldState,
+		},
+		"first recognized type wins": {
+			classifiedAs: []Reference{
+				{ID: "http://vocab.getty.edu/aat/1", Label: "deserted settlements"},
+				{ID: "http://vocab.getty.edu/aat/2", Label: "towns"},
+			},
+			expected: fieldCity,
+		},
+		"unknown": {
+			classifiedAs: []Reference{{ID: "http://vocab.getty.edu/aat/300386699", Label: "facets"}},
+			expected:     fieldNone,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := placeType(Place{ClassifiedAs: tc.classifiedAs}); got != tc.expected {
+				t.Fatalf("expected %d, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseID(t *testing.T) {
+	tests := map[string]string{
+		"http://vocab.getty.edu/page/tgn/7013416":    "7013416",
+		"https://vocab.getty.edu/tgn/7013416":        "7013416",
+		" http://vocab.getty.edu/tgn/7013416.json ":  "7013416",
+		"http://vocab.getty.edu/tgn/7013416/":        "7013416",
+		"https://example.com/x/7013416":              "",
+		"http://vocab.getty.edu/aat/300008347":       "",
+		"http://vocab.getty.edu.example.com/tgn/701": "",
+		"http://vocab.getty.edu/tgn/7013416/extra":   "",
+		"vocab.getty.edu/tgn/7013416":                "",
+	}
+	for uri, want := range tests {
+		got, err := ParseID(uri)
+		if want == "" && !errors.Is(err, ErrInvalidURI) {
+			t.Errorf("%s: expected ErrInvalidURI, got %q, %v", uri, got, err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %q, got %q", uri, want, got)
+		}
+	}
+}
+
+// Anything other than a TGN URI is rejected before Getty is asked.
+func TestResolveRejectsOtherURIs(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("unexpected request: %s", r.URL)
+	}))
+	if _, err := client.Resolve(context.Background(), "https://example.com/x/7013416"); !errors.Is(err, ErrInvalidURI) {
+		t.Fatalf("expected ErrInvalidURI, got %v", err)
+	}
+}
+
+func TestResolveUsesCache(t *testing.T) {
+	var requests atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fixtures().ServeHTTP(w, r)
+	}))
+
+	for range 500 {
+		if _, err := client.Resolve(context.Background(), "http://vocab.getty.edu/page/tgn/7013416"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// Bethlehem, its two counties and four more ancestors
+	if got := requests.Load(); got != 7 {
+		t.Fatalf("expected 7 requests to Getty, got %d", got)
+	}
+}
+
+func TestResolveRetries(t *testing.T) {
+	tests := map[string]struct {
+		failures     int32
+		status       int
+		wantErr      bool
+		wantRequests int32
+	}{
+		"recovers from 503": {
+			failures:     2,
+			status:       http.StatusServiceUnavailable,
+			wantRequests: 3,
+		},
+		"recovers from 429": {
+			failures:     1,
+			status:       http.StatusTooManyRequests,
+			wantRequests: 2,
+		},
+		"gives up after max retries": {
+			failures:     10,
+			status:       http.StatusBadGateway,
+			wantErr:      true,
+			wantRequests: 4,
+		},
+		"does not retry a 404": {
+			failures:     10,
+			status:       http.StatusNotFound,
+			wantErr:      true,
+			wantRequests: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests atomic.Int32
+			client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= tc.failures {
+					w.WriteHeader(tc.status)
+					return
+				}
+				fixtures().ServeHTTP(w, r)
+			}))
+
+			// World has no parents so only one place is fetched
+			_, err := client.Resolve(context.Background(), "http://vocab.getty.edu/tgn/7029392")
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if got := requests.Load(); got != tc.wantRequests {
+				t.Fatalf("expected %d requests, got %d", tc.wantRequests, got)
+			}
+		})
+	}
+}
+
+func TestResolveTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	client.HTTPClient.Timeout = 20 * time.Millisecond
+	client.MaxRetries = 1
+
+	start := time.Now()
+	if _, err := client.Resolve(context.Background(), "http://vocab.getty.edu/tgn/7029392"); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("hung Getty request took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.Resolve(ctx, "http://vocab.getty.edu/tgn/7029392")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled context to fail, got %v", err)
+	}
+}
@@ -2,19 +2,27 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/lehigh-university-libraries/fabricator/internal/handlers"
+	"github.com/lehigh-university-libraries/fabricator/internal/tgn"
 )
 
 func main() {
@@ -25,8 +33,49 @@ func main() {
 	checkCSV := flag.String("check-csv", "", "Path to CSV file to run through check (prints JSON result)")
 	transformCSV := flag.String("transform-csv", "", "Path to CSV file to run through transform (writes ZIP output)")
 	transformOut := flag.String("transform-out", "", "Output path for transform ZIP (default: <input>.zip)")
+	dryRun := flag.Bool("dry-run", false, "With --transform-csv, list the taxonomy terms that would be created instead of creating them")
+	workbenchTerms := flag.Bool("workbench-terms", false, "With --transform-csv, write missing taxonomy terms to target.agents.csv for Workbench to create instead of creating them")
 	flag.Parse()
 
+	if err := handlers.LoadColumnMappings(os.Getenv("FABRICATOR_COLUMN_MAPPING")); err != nil {
+		slog.Error("failed loading column mapping", "err", err)
+		os.Exit(1)
+	}
+	if err := handlers.LoadCheckRules(os.Getenv("FABRICATOR_CHECK_RULES")); err != nil {
+		slog.Error("failed loading check rules", "err", err)
+		os.Exit(1)
+	}
+	handlers.ConfigureJWKS(os.Getenv("FABRICATOR_JWKS_URL"))
+	if err := handlers.LoadAuthPolicy(os.Getenv("FABRICATOR_AUTH_CONFIG")); err != nil {
+		slog.Error("failed loading auth policy", "err", err)
+		os.Exit(1)
+	}
+	handlers.WarnIfGoogleDisabled()
+	if err := handlers.LoadAPIKeys(os.Getenv("FABRICATOR_API_KEYS_FILE"), os.Getenv("FABRICATOR_API_KEYS")); err != nil {
+		slog.Error("failed loading API keys", "err", err)
+		os.Exit(1)
+	}
+	if err := handlers.ConfigureAuditLog(os.Getenv("FABRICATOR_AUDIT_LOG")); err != nil {
+		slog.Error("failed opening audit log", "err", err)
+		os.Exit(1)
+	}
+	if err := configureTGN(); err != nil {
+		slog.Error("failed configuring TGN lookups", "err", err)
+		os.Exit(1)
+	}
+	if err := configureTermCache(); err != nil {
+		slog.Error("failed configuring term cache", "err", err)
+		os.Exit(1)
+	}
+	if raw := os.Getenv("FABRICATOR_DUPLICATE_SCORE"); raw != "" {
+		score, err := strconv.ParseFloat(raw, 64)
+		if err != nil || score < 0 || score > 1 {
+			slog.Error("invalid FABRICATOR_DUPLICATE_SCORE, expected a number from 0 to 1", "value", raw)
+			os.Exit(1)
+		}
+		handlers.ConfigureDuplicateMatching(score)
+	}
+
 	if *checkCSV != "" || *transformCSV != "" {
 		if *checkCSV != "" {
 			if err := runCheckCSV(*checkCSV); err != nil {
@@ -39,12 +88,13 @@ func main() {
 			if out == "" {
 				out = fmt.Sprintf("%s.zip", strings.TrimSuffix(*transformCSV, filepath.Ext(*transformCSV)))
 			}
-			if err := runTransformCSV(*transformCSV, out); err != nil {
+			if err := runTransformCSV(*transformCSV, out, *dryRun, *workbenchTerms); err != nil {
 				slog.Error("transform-csv failed", "err", err)
 				os.Exit(1)
 			}
 			fmt.Println(out)
 		}
+		flushTGNCache()
 		return
 	}
 
@@ -65,17 +115,43 @@ func main() {
 	if os.Getenv("ISLE_SITE_URL") == "" {
 		os.Setenv("ISLE_SITE_URL", "https://preserve.lehigh.edu")
 	}
-	http.HandleFunc("/workbench/check", handlers.CheckMyWork)
-	http.HandleFunc("/workbench/transform", handlers.TransformCsv)
+	http.HandleFunc("/workbench/check", handlers.RequireRole(handlers.RoleViewer, handlers.CheckMyWork))
+	http.HandleFunc("/workbench/transform", handlers.RequireRole(handlers.RoleEditor, handlers.TransformCsv))
+	http.HandleFunc("/tgn/search", handlers.RequireRole(handlers.RoleViewer, handlers.SearchTGN))
+	http.HandleFunc("/admin/term-cache", handlers.RequireRole(handlers.RoleIngester, handlers.FlushTermCache))
 	http.HandleFunc("/healthcheck", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintln(w, "OK")
 	})
 
+	// finish in-flight requests and save the TGN cache on SIGINT or SIGTERM
+	srv := &http.Server{Addr: ":8080"}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		slog.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed shutting down cleanly", "err", err)
+		}
+	}()
+
 	slog.Info("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 		panic(err)
 	}
+	<-stopped
+	flushTGNCache()
+}
+
+func flushTGNCache() {
+	if err := tgn.CloseCache(); err != nil {
+		slog.Error("failed saving TGN cache", "err", err)
+	}
 }
 
 func runCheckCSV(path string) error {
@@ -99,9 +175,7 @@ func runCheckCSV(path string) error {
 
 	req := httptest.NewRequest(http.MethodPost, "/workbench/check", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
-	if os.Getenv("SHARED_SECRET") != "" {
-		req.Header.Set("X-Secret", os.Getenv("SHARED_SECRET"))
-	}
+	req = req.WithContext(handlers.WithIdentity(req.Context(), cliIdentity()))
 	rec := httptest.NewRecorder()
 	handlers.CheckMyWork(rec, req)
 
@@ -124,15 +198,23 @@ func runCheckCSV(path string) error {
 	return nil
 }
 
-func runTransformCSV(path, out string) error {
+func runTransformCSV(path, out string, dryRun, workbenchTerms bool) error {
 	file, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	req := httptest.NewRequest(http.MethodPost, "/workbench/transform", file)
+	query := url.Values{}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+	if workbenchTerms {
+		query.Set("terms", "workbench")
+	}
+	req := httptest.NewRequest(http.MethodPost, "/workbench/transform?"+query.Encode(), file)
 	req.Header.Set("Content-Type", "text/csv")
+	req = req.WithContext(handlers.WithIdentity(req.Context(), cliIdentity()))
 	if info, err := os.Stat(path); err == nil {
 		req.ContentLength = info.Size()
 	}
@@ -151,3 +233,71 @@ func runTransformCSV(path, out string) error {
 	}
 	return os.WriteFile(out, raw, 0644)
 }
+
+// cliIdentity is who CLI runs are recorded as. The CLI calls the handlers in
+// process, so RequireAuth never sees its requests, and whoever runs it
+// already has the Drupal credentials needed to create terms.
+func cliIdentity() handlers.Identity {
+	name := os.Getenv("USER")
+	if name == "" {
+		name = "cli"
+	}
+	return handlers.Identity{Name: name, Method: "cli", Role: handlers.RoleIngester}
+}
+
+// configureTGN sets up Getty TGN lookups from FABRICATOR_TGN_BASE_URL
+// (optional mirror), FABRICATOR_TGN_CACHE_TTL (a Go duration, default 24h)
+// and FABRICATOR_TGN_CACHE_FILE (optional JSON snapshot path).
+func configureTGN() error {
+	if baseURL := os.Getenv("FABRICATOR_TGN_BASE_URL"); baseURL != "" {
+		tgn.DefaultClient.BaseURL = baseURL
+	}
+	ttl := tgn.DefaultCacheTTL
+	if raw := os.Getenv("FABRICATOR_TGN_CACHE_TTL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid FABRICATOR_TGN_CACHE_TTL %q: %w", raw, err)
+		}
+		ttl = d
+	}
+	return tgn.ConfigureCache(ttl, os.Getenv("FABRICATOR_TGN_CACHE_FILE"))
+}
+
+// configureTermCache sizes the contributor term cache shared by transforms
+// from FABRICATOR_TERM_CACHE_TTL and FABRICATOR_TERM_CACHE_NEGATIVE_TTL (Go
+// durations, default 1h and 5m) and FABRICATOR_TERM_CACHE_SIZE (entries,
+// default 10000). A zero TTL or size turns that part of the cache off.
+// FABRICATOR_TERM_LOOKUP_WORKERS sets how many lookups run at once (default 8).
+func configureTermCache() error {
+	ttl, negativeTTL, size := handlers.DefaultTermCacheTTL, handlers.DefaultTermCacheNegativeTTL, handlers.DefaultTermCacheSize
+	for env, d := range map[string]*time.Duration{
+		"FABRICATOR_TERM_CACHE_TTL":          &ttl,
+		"FABRICATOR_TERM_CACHE_NEGATIVE_TTL": &negativeTTL,
+	} {
+		raw := os.Getenv(env)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", env, raw, err)
+		}
+		*d = parsed
+	}
+	if raw := os.Getenv("FABRICATOR_TERM_LOOKUP_WORKERS"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid FABRICATOR_TERM_LOOKUP_WORKERS %q", raw)
+		}
+		handlers.ConfigureLookupWorkers(n)
+	}
+	if raw := os.Getenv("FABRICATOR_TERM_CACHE_SIZE"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid FABRICATOR_TERM_CACHE_SIZE %q", raw)
+		}
+		size = n
+	}
+	handlers.ConfigureTermCache(ttl, negativeTTL, size)
+	return nil
+}
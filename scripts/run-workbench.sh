@@ -24,6 +24,17 @@ if [ -f input_data/target.update.csv ]; then
   grep ERROR logs/update.log | grep -Ev '"supplemental_file" in .* not created because CSV field is empty' && exit 1 || echo "No errors"
 fi
 
+# terms referenced by name in target.csv, from transform.sh's terms=workbench
+if [ -f input_data/target.corporate_bodies.csv ]; then
+  python3 workbench --config configs/corporate_bodies.yml
+  grep ERROR logs/corporate_bodies.log | grep -Ev '"supplemental_file" in .* not created because CSV field is empty' && exit 1 || echo "No errors"
+fi
+
+if [ -f input_data/target.agents.csv ]; then
+  python3 workbench --config configs/terms.yml
+  grep ERROR logs/agents.log | grep -Ev '"supplemental_file" in .* not created because CSV field is empty' && exit 1 || echo "No errors"
+fi
+
 if [ -f input_data/target.csv ]; then
   python3 workbench --config configs/create.yml
   grep ERROR logs/items.log | grep -Ev '"supplemental_file" in .* not created because CSV field is empty' && exit 1 || echo "No errors"
@@ -4,6 +4,8 @@ set -eou pipefail
 
 GSHEET=$(cat gsheet.json)
 WORKBENCH_BASE_URL="${WORKBENCH_BASE_URL:-https://islandora-test.lib.lehigh.edu}"
+# set to true to have workbench create missing terms instead of fabricator
+WORKBENCH_TERMS="${WORKBENCH_TERMS:-false}"
 
 if echo "$GSHEET" | jq -e .values >/dev/null; then
   NORMALIZED_VALUES=$(echo "$GSHEET" | jq -c '
@@ -42,13 +44,23 @@ if [ "${STATUS}" != 200 ]; then
   exit 1
 fi
 
-if [[ "$(jq '. | length' check.json)" -gt 0 ]]; then
+# warnings are worth a look but only errors block the ingest
+if [[ "$(jq '.warnings' check.json)" -gt 0 ]]; then
+  echo "Check my work found warnings"
+  jq '.findings[] | select(.severity == "warning")' check.json
+fi
+
+if [[ "$(jq '.errors' check.json)" -gt 0 ]]; then
   echo "Check my work failed"
-  jq . check.json
+  jq '.findings[] | select(.severity == "error")' check.json
   exit 1
 fi
 
 # transform google sheet to a workbench CSV
+QUERY="sheet=$(jq -rn --arg url "$URL" '$url|@uri')"
+if [ "$WORKBENCH_TERMS" = "true" ]; then
+  QUERY="$QUERY&terms=workbench"
+fi
 STATUS=$(curl -s \
   -w '%{http_code}' \
   --cacert /etc/ssl/certs/isle.pem \
@@ -56,7 +68,7 @@ STATUS=$(curl -s \
   -XPOST \
   -o target.zip \
   --upload-file source.csv \
-  "$WORKBENCH_BASE_URL/workbench/transform")
+  "$WORKBENCH_BASE_URL/workbench/transform?$QUERY")
 if [ "${STATUS}" -gt 299 ] || [ "${STATUS}" -lt 200 ]; then
   echo "CSV transform failed"
   exit 1